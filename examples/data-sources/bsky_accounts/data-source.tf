@@ -0,0 +1,14 @@
+provider "bsky" {
+  pds_host           = "https://bsky.social"
+  handle             = "scoott.blog"
+  pds_admin_password = "<PDS admin password>"
+}
+
+data "bsky_accounts" "active" {
+  handle_suffix = ".scoott.blog"
+  status        = "active"
+}
+
+output "unmanaged-accounts" {
+  value = setsubtract(data.bsky_accounts.active.accounts[*].did, [bsky_account.test-account.did])
+}
@@ -0,0 +1,14 @@
+provider "bsky" {
+  pds_host = "https://bsky.social"
+  handle   = "scoott.blog"
+}
+
+data "bsky_server" "pds" {}
+
+output "handle-domains" {
+  value = data.bsky_server.pds.available_user_domains
+}
+
+output "invite-code-required" {
+  value = data.bsky_server.pds.invite_code_required
+}
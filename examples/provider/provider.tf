@@ -2,4 +2,27 @@ provider "bsky" {
   pds_host = "https://bsky.social" // or set via the BSKY_PDS_HOST env var
   handle   = "scoott.blog"         // or set via the BSKY_HANDLE   env var
   password = "<password>"          // or set via the BSKY_PASSWORD env var
-}
\ No newline at end of file
+}
+// example using the tokens of an existing session instead of a password
+provider "bsky" {
+  alias       = "ci"
+  pds_host    = "https://bsky.social"
+  access_jwt  = var.bsky_access_jwt  // or set via the BSKY_ACCESS_JWT  env var
+  refresh_jwt = var.bsky_refresh_jwt // or set via the BSKY_REFRESH_JWT env var
+}
+
+// example using OAuth instead of a password, the first run returns the URL to authorize the provider
+provider "bsky" {
+  alias              = "oauth"
+  pds_host           = "https://bsky.social"
+  handle             = "scoott.blog" // used as the login hint
+  session_cache_path = "${path.root}/.bsky-sessions"
+
+  oauth = {
+    client_id          = "https://terraform.example.com/client-metadata.json"
+    client_key_id      = "terraform-1"
+    client_private_key = file("client-key.pem")
+    redirect_uri       = "https://terraform.example.com/callback"
+    authorization_code = var.bsky_oauth_authorization_code
+  }
+}
@@ -1,2 +1,9 @@
 # Accounts can be imported using the DID
-terraform import bsky_account.test-account "did:plc:ewvi7nxzyoun6zhxrhs64oiz"
\ No newline at end of file
+terraform import bsky_account.test-account "did:plc:ewvi7nxzyoun6zhxrhs64oiz"
+
+# or using the account's handle or email
+terraform import bsky_account.test-account "handle:test.scoott.blog"
+terraform import bsky_account.test-account "email:test@scoott.blog"
+
+# The password of an imported account is not known, a configured password is only recorded
+# in the state. Use password_wo and password_wo_version to set a new password.
@@ -8,18 +8,34 @@ resource "bsky_account" "test-account" {
   email  = "test@scoott.blog"
   handle = "test.scoott.blog"
   // if account password is not specified when creating a new user, one will be autogenerated
+  // and stored in the sensitive generated_password attribute
+  rotate_password_trigger = "2025-01" // change to generate a new password
+}
+
+output "test-account-password" {
+  value     = bsky_account.test-account.generated_password
+  sensitive = true
+}
+
+// example using a write-only password that is never stored in the Terraform state
+resource "bsky_account" "test-account-wo" {
+  email               = "test-wo@scoott.blog"
+  handle              = "test-wo.scoott.blog"
+  password_wo         = "<password>"
+  password_wo_version = 1 // increment to apply a new password_wo
 }
 
 
-// example using a bsky_account to create a Cloudflare DNS TXT record with the DID to validate the handle
+// example using the computed verification values of a bsky_account to create the Cloudflare DNS TXT record
+// that verifies the handle
 provider "cloudflare" {
   api_token = "<cloudflare api token>"
 }
 
 resource "cloudflare_dns_record" "test-account-dns-verify" {
   zone_id = "<cloudflare zone id>"
-  name    = "_atproto.${element(split(".", bsky_account.test-account.handle), 0)}"
-  content = "\"did=${bsky_account.test-account.did}\""
+  name    = bsky_account.test-account.handle_dns_record_name
+  content = "\"${bsky_account.test-account.handle_dns_record_value}\""
   comment = "Bluesky handle verification record for ${bsky_account.test-account.handle}"
   ttl     = 1 // auto
   type    = "TXT"
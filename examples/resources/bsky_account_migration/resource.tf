@@ -0,0 +1,13 @@
+resource "bsky_account_migration" "scoott" {
+  old_pds_host   = "https://bsky.social"
+  old_identifier = "scoott.blog"
+  old_password   = "<password on bsky.social>"
+
+  new_pds_host = "https://pds.scoott.blog"
+  new_handle   = "scoott.pds.scoott.blog"
+  new_password = "<password on the new PDS>"
+  new_email    = "scott@scoott.blog"
+
+  // emailed by the old PDS, the first apply requests it and fails until it is set
+  plc_token = "<token>"
+}
@@ -0,0 +1,18 @@
+provider "bsky" {
+  pds_host = "https://bsky.social"
+  handle   = "scoott.blog"
+}
+
+resource "bsky_app_password" "bot" {
+  name = "release-announcer"
+}
+
+resource "bsky_app_password" "dm-bot" {
+  name       = "support-inbox"
+  privileged = true
+}
+
+output "bot_password" {
+  value     = bsky_app_password.bot.password
+  sensitive = true
+}
@@ -0,0 +1,23 @@
+provider "bsky" {
+  pds_host = "https://bsky.social"
+  handle   = "scoott.blog"
+}
+
+resource "bsky_handle" "custom-domain" {
+  handle               = "scott.example.com"
+  verification_timeout = "15m"
+}
+
+// example using the computed verification values to create the Cloudflare DNS TXT record that verifies the handle
+provider "cloudflare" {
+  api_token = "<cloudflare api token>"
+}
+
+resource "cloudflare_dns_record" "custom-domain-verify" {
+  zone_id = "<cloudflare zone id>"
+  name    = bsky_handle.custom-domain.dns_record_name
+  content = "\"${bsky_handle.custom-domain.dns_record_value}\""
+  comment = "Bluesky handle verification record for ${bsky_handle.custom-domain.handle}"
+  ttl     = 1 // auto
+  type    = "TXT"
+}
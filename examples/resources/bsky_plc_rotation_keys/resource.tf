@@ -0,0 +1,19 @@
+provider "bsky" {
+  pds_host = "https://bsky.social"
+  handle   = "scoott.blog"
+}
+
+variable "plc_token" {
+  type      = string
+  sensitive = true
+  ephemeral = true
+  default   = null
+}
+
+// the first apply emails a token to the account, set it with -var plc_token=... and apply again
+resource "bsky_plc_rotation_keys" "keys" {
+  rotation_keys = [
+    "did:key:zQ3shhCGUqDKjStzuDxPkTxN6ujddP4RkEKJJouJGRRkaLGbg",
+  ]
+  plc_token = var.plc_token
+}
@@ -0,0 +1,16 @@
+provider "bsky" {
+  pds_host = "https://bsky.social"
+  handle   = "scoott.blog"
+}
+
+// back up the authenticated account's repo, a new CAR file is written whenever the repo changes
+resource "bsky_repo_backup" "scoott" {
+  directory     = "${path.module}/backups/scoott.blog"
+  include_blobs = true
+}
+
+// back up another repo by DID
+resource "bsky_repo_backup" "org" {
+  did       = "did:plc:ewvi7nxzyoun6zhxrhs64oiz"
+  directory = "${path.module}/backups/org"
+}
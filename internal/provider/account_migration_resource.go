@@ -0,0 +1,478 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/bluesky-social/indigo/api/agnostic"
+	"github.com/bluesky-social/indigo/api/atproto"
+	"github.com/bluesky-social/indigo/atproto/syntax"
+	"github.com/bluesky-social/indigo/xrpc"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// Ensure the implementation satisfies the expected interfaces.
+var (
+	_ resource.Resource              = &accountMigrationResource{}
+	_ resource.ResourceWithConfigure = &accountMigrationResource{}
+)
+
+// Migration steps, in the order they are performed.
+const (
+	migrationStepAccountCreated        = "account_created"
+	migrationStepRepoImported          = "repo_imported"
+	migrationStepBlobsTransferred      = "blobs_transferred"
+	migrationStepPreferencesMigrated   = "preferences_migrated"
+	migrationStepIdentityUpdated       = "identity_updated"
+	migrationStepAccountActivated      = "account_activated"
+	migrationStepOldAccountDeactivated = "old_account_deactivated"
+)
+
+// NewAccountMigrationResource is a helper function to simplify the provider implementation.
+func NewAccountMigrationResource() resource.Resource {
+	return &accountMigrationResource{}
+}
+
+// accountMigrationResource is the resource implementation.
+type accountMigrationResource struct {
+	client *xrpc.Client
+}
+
+type accountMigrationResourceModel struct {
+	OldPDSHost    types.String `tfsdk:"old_pds_host"`
+	OldIdentifier types.String `tfsdk:"old_identifier"`
+	OldPassword   types.String `tfsdk:"old_password"`
+	NewPDSHost    types.String `tfsdk:"new_pds_host"`
+	NewHandle     types.String `tfsdk:"new_handle"`
+	NewPassword   types.String `tfsdk:"new_password"`
+	NewEmail      types.String `tfsdk:"new_email"`
+	InviteCode    types.String `tfsdk:"invite_code"`
+	PLCToken      types.String `tfsdk:"plc_token"`
+	Did           types.String `tfsdk:"did"`
+}
+
+// Metadata returns the resource type name.
+func (l *accountMigrationResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_account_migration"
+}
+
+// Schema defines the schema for the resource.
+func (r *accountMigrationResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	requiresReplace := []planmodifier.String{
+		stringplanmodifier.RequiresReplace(),
+	}
+
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "Migrate an account from one PDS to another, keeping its DID. " +
+			"Adapted from the [goat account migration](https://github.com/bluesky-social/indigo/blob/main/cmd/goat/account_migrate.go): " +
+			"the account is created on the new PDS with service auth, the repo, blobs and preferences are copied, " +
+			"the DID's PLC operation is signed by the old PDS and submitted by the new PDS, and the old account is deactivated. " +
+			"If an apply fails the resource is tainted, and the next apply resumes the migration, skipping the steps the old and new PDS show as completed. " +
+			"Destroying this resource does not undo the migration.",
+		Attributes: map[string]schema.Attribute{
+			"old_pds_host": schema.StringAttribute{
+				MarkdownDescription: "Base URL of the PDS the account is migrated from, e.g. `https://bsky.social`",
+				Required:            true,
+				PlanModifiers:       requiresReplace,
+			},
+			"old_identifier": schema.StringAttribute{
+				MarkdownDescription: "Handle or DID of the account on the old PDS",
+				Required:            true,
+				PlanModifiers:       requiresReplace,
+			},
+			"old_password": schema.StringAttribute{
+				MarkdownDescription: "Write-only password of the account on the old PDS, never stored in the Terraform state. " +
+					"App passwords can't be used to migrate accounts. Requires Terraform 1.11 or later.",
+				Required:  true,
+				Sensitive: true,
+				WriteOnly: true,
+			},
+			"new_pds_host": schema.StringAttribute{
+				MarkdownDescription: "Base URL of the PDS the account is migrated to",
+				Required:            true,
+				PlanModifiers:       requiresReplace,
+			},
+			"new_handle": schema.StringAttribute{
+				MarkdownDescription: "Handle of the account on the new PDS",
+				Required:            true,
+				PlanModifiers:       requiresReplace,
+			},
+			"new_password": schema.StringAttribute{
+				MarkdownDescription: "Write-only password of the account on the new PDS, never stored in the Terraform state. Requires Terraform 1.11 or later.",
+				Required:            true,
+				Sensitive:           true,
+				WriteOnly:           true,
+			},
+			"new_email": schema.StringAttribute{
+				MarkdownDescription: "Email of the account on the new PDS",
+				Optional:            true,
+				PlanModifiers:       requiresReplace,
+			},
+			"invite_code": schema.StringAttribute{
+				MarkdownDescription: "Invite code for the new PDS, if it requires one",
+				Optional:            true,
+			},
+			"plc_token": schema.StringAttribute{
+				MarkdownDescription: "Write-only token emailed by the old PDS to authorize signing the PLC operation. The token can only be used once and is never stored in the Terraform state. " +
+					"If not specified or no longer valid when the identity needs to be updated, a new token is requested and the apply fails until it is set. " +
+					"Requires Terraform 1.11 or later.",
+				Optional:  true,
+				Sensitive: true,
+				WriteOnly: true,
+			},
+			"did": schema.StringAttribute{
+				MarkdownDescription: "The DID of the migrated account",
+				Computed:            true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+		},
+	}
+}
+
+func (l *accountMigrationResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	// Retrieve values from a plan.
+	var plan accountMigrationResourceModel
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+	if diags.HasError() {
+		return
+	}
+
+	// The credentials are write-only, so they are only available in the configuration.
+	var config accountMigrationResourceModel
+	diags = req.Config.Get(ctx, &config)
+	resp.Diagnostics.Append(diags...)
+	if diags.HasError() {
+		return
+	}
+	plan.OldPassword = config.OldPassword
+	plan.NewPassword = config.NewPassword
+	plan.PLCToken = config.PLCToken
+
+	diags = l.migrate(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+	plan.OldPassword = types.StringNull()
+	plan.NewPassword = types.StringNull()
+	plan.PLCToken = types.StringNull()
+
+	// Record the DID even when a step failed.
+	if plan.Did.IsUnknown() {
+		// nothing was done, so there is nothing to record
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+}
+
+// Read refreshes the Terraform state with the latest data.
+func (l *accountMigrationResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	// The migration is a one-off operation, checking its progress would require logging in to both PDSes, so the
+	// state is kept as is.
+}
+
+// Update updates the resource and sets the updated Terraform state on success.
+func (l *accountMigrationResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	// Only the invite code can change without replacing the resource, it is only used during the migration.
+	var plan accountMigrationResourceModel
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+	if diags.HasError() {
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+}
+
+// Delete removes the Terraform state, the account stays on the new PDS.
+func (l *accountMigrationResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+}
+
+// Configure adds the provider configured client to the resource.
+func (l *accountMigrationResource) Configure(_ context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	// Add a nil check when handling ProviderData because Terraform
+	// sets that data after it calls the ConfigureProvider RPC.
+	if req.ProviderData == nil {
+		return
+	}
+
+	client, ok := req.ProviderData.(*xrpc.Client)
+
+	if !ok {
+		resp.Diagnostics.AddError(
+			"Unexpected Data Source Configure Type",
+			fmt.Sprintf("Expected *xrpc.Client, got: %T. Please report this issue to the provider developers.", req.ProviderData),
+		)
+
+		return
+	}
+
+	l.client = client
+}
+
+// newClient creates an unauthenticated client for the host that shares the provider's HTTP settings.
+func (l *accountMigrationResource) newClient(host string) *xrpc.Client {
+	client := &xrpc.Client{
+		Host: host,
+	}
+	if l.client != nil {
+		client.Client = l.client.Client
+		client.UserAgent = l.client.UserAgent
+	}
+	return client
+}
+
+// migrate performs the remaining migration steps. Every step checks the state of the old and new PDS first,
+// so a failed migration can be resumed.
+func (l *accountMigrationResource) migrate(ctx context.Context, plan *accountMigrationResourceModel) diag.Diagnostics {
+	var diags diag.Diagnostics
+	completedSteps := []string{}
+
+	addError := func(detail string, err error) diag.Diagnostics {
+		errDiag := xrpcErrorDiagnostic("Error migrating account", detail, err)
+		diags.AddError(
+			errDiag.Summary(),
+			errDiag.Detail()+"\n\nCompleted steps: "+fmt.Sprint(completedSteps)+". The next apply resumes the migration.",
+		)
+		return diags
+	}
+
+	// Log in to the old PDS.
+	oldClient := l.newClient(plan.OldPDSHost.ValueString())
+	oldSession, err := atproto.ServerCreateSession(ctx, oldClient, &atproto.ServerCreateSession_Input{
+		Identifier: plan.OldIdentifier.ValueString(),
+		Password:   plan.OldPassword.ValueString(),
+	})
+	if err != nil {
+		return addError("Could not log in to the old PDS", err)
+	}
+	did := oldSession.Did
+	oldClient.Auth = &xrpc.AuthInfo{
+		AccessJwt:  oldSession.AccessJwt,
+		RefreshJwt: oldSession.RefreshJwt,
+		Did:        did,
+		Handle:     oldSession.Handle,
+	}
+	plan.Did = types.StringValue(did)
+	ctx = tflog.SetField(ctx, "did", did)
+
+	// Create the account on the new PDS, unless a previous apply already did.
+	newClient := l.newClient(plan.NewPDSHost.ValueString())
+	newSession, err := atproto.ServerCreateSession(ctx, newClient, &atproto.ServerCreateSession_Input{
+		Identifier: did,
+		Password:   plan.NewPassword.ValueString(),
+	})
+	// the new PDS rejects the credentials of a DID it has no account for
+	if err != nil && xrpcErrorName(err) != "AccountNotFound" && !isInvalidTokenError(err) {
+		return addError("Could not log in to the new PDS", err)
+	}
+	if err != nil {
+		tflog.Info(ctx, "Creating account on the new PDS")
+
+		newServer, err := atproto.ServerDescribeServer(ctx, newClient)
+		if err != nil {
+			return addError("Could not describe the new PDS", err)
+		}
+		newServerDid, err := syntax.ParseDID(newServer.Did)
+		if err != nil {
+			return addError("Could not parse the new PDS DID", err)
+		}
+
+		// the old PDS vouches for the DID through a short-lived service auth token
+		serviceAuth, err := atproto.ServerGetServiceAuth(ctx, oldClient, newServerDid.String(), time.Now().Add(time.Minute).Unix(), "com.atproto.server.createAccount")
+		if err != nil {
+			return addError("Could not get a service auth token from the old PDS", err)
+		}
+
+		createAccountInput := &atproto.ServerCreateAccount_Input{
+			Did:        &did,
+			Handle:     plan.NewHandle.ValueString(),
+			Password:   plan.NewPassword.ValueStringPointer(),
+			Email:      plan.NewEmail.ValueStringPointer(),
+			InviteCode: plan.InviteCode.ValueStringPointer(),
+		}
+		newClient.Auth = &xrpc.AuthInfo{
+			AccessJwt:  serviceAuth.Token,
+			RefreshJwt: serviceAuth.Token,
+			Did:        did,
+			Handle:     plan.NewHandle.ValueString(),
+		}
+		createdAccount, err := atproto.ServerCreateAccount(ctx, newClient, createAccountInput)
+		if err != nil {
+			return addError("Could not create the account on the new PDS", err)
+		}
+		if createdAccount.Did != did {
+			return addError("Could not create the account on the new PDS", fmt.Errorf("new account DID %s does not match %s", createdAccount.Did, did))
+		}
+		newClient.Auth = &xrpc.AuthInfo{
+			AccessJwt:  createdAccount.AccessJwt,
+			RefreshJwt: createdAccount.RefreshJwt,
+			Did:        did,
+			Handle:     createdAccount.Handle,
+		}
+	} else {
+		newClient.Auth = &xrpc.AuthInfo{
+			AccessJwt:  newSession.AccessJwt,
+			RefreshJwt: newSession.RefreshJwt,
+			Did:        did,
+			Handle:     newSession.Handle,
+		}
+	}
+	completedSteps = append(completedSteps, migrationStepAccountCreated)
+
+	newStatus, err := atproto.ServerCheckAccountStatus(ctx, newClient)
+	if err != nil {
+		return addError("Could not check the account status on the new PDS", err)
+	}
+
+	// Import the repo, unless the new PDS is already at the latest revision. The old repo can no longer change
+	// once the new account is active.
+	if !newStatus.Activated {
+		latestCommit, err := atproto.SyncGetLatestCommit(ctx, oldClient, did)
+		if err != nil {
+			return addError("Could not get the latest commit from the old PDS", err)
+		}
+		if latestCommit.Rev != newStatus.RepoRev {
+			tflog.Info(ctx, "Migrating repo", map[string]any{"rev": latestCommit.Rev})
+
+			repo, err := atproto.SyncGetRepo(ctx, oldClient, did, "")
+			if err != nil {
+				return addError("Could not export the repo from the old PDS", err)
+			}
+			err = atproto.RepoImportRepo(ctx, newClient, bytes.NewReader(repo))
+			if err != nil {
+				return addError("Could not import the repo to the new PDS", err)
+			}
+		}
+	}
+	completedSteps = append(completedSteps, migrationStepRepoImported)
+
+	// Transfer the blobs the new PDS is missing.
+	cursor := ""
+	failedBlobs := 0
+	for {
+		missingBlobs, err := atproto.RepoListMissingBlobs(ctx, newClient, cursor, 500)
+		if err != nil {
+			return addError("Could not list the missing blobs on the new PDS", err)
+		}
+		for _, blob := range missingBlobs.Blobs {
+			blobBytes, err := atproto.SyncGetBlob(ctx, oldClient, blob.Cid, did)
+			if err == nil {
+				_, err = atproto.RepoUploadBlob(ctx, newClient, bytes.NewReader(blobBytes))
+			}
+			if err != nil {
+				tflog.Warn(ctx, "Failed to transfer blob", map[string]any{"cid": blob.Cid, "error": err.Error()})
+				failedBlobs++
+				continue
+			}
+			tflog.Debug(ctx, "Transferred blob", map[string]any{"cid": blob.Cid, "size": len(blobBytes)})
+		}
+		if missingBlobs.Cursor == nil || *missingBlobs.Cursor == "" || len(missingBlobs.Blobs) == 0 {
+			break
+		}
+		cursor = *missingBlobs.Cursor
+	}
+	if failedBlobs > 0 {
+		diags.AddWarning(
+			"Some blobs were not migrated",
+			fmt.Sprintf("Could not transfer %d blobs from the old PDS, see the logs for details. Records referring to them will have missing images or videos.", failedBlobs),
+		)
+	}
+	completedSteps = append(completedSteps, migrationStepBlobsTransferred)
+
+	// Copy the preferences, this is idempotent so it is always done.
+	preferences, err := agnostic.ActorGetPreferences(ctx, oldClient)
+	if err != nil {
+		return addError("Could not get the preferences from the old PDS", err)
+	}
+	err = agnostic.ActorPutPreferences(ctx, newClient, &agnostic.ActorPutPreferences_Input{
+		Preferences: preferences.Preferences,
+	})
+	if err != nil {
+		return addError("Could not put the preferences on the new PDS", err)
+	}
+	completedSteps = append(completedSteps, migrationStepPreferencesMigrated)
+
+	// Point the DID to the new PDS.
+	if !newStatus.ValidDid {
+		requestPLCToken := func(detail string) diag.Diagnostics {
+			err := atproto.IdentityRequestPlcOperationSignature(ctx, oldClient)
+			if err != nil {
+				return addError("Could not request a PLC operation token from the old PDS", err)
+			}
+			diags.AddAttributeError(
+				path.Root("plc_token"),
+				"PLC operation token required",
+				detail+" Set plc_token to the token and apply again to resume the migration.",
+			)
+			return diags
+		}
+		if plan.PLCToken.ValueString() == "" {
+			return requestPLCToken("The old PDS has emailed a token to authorize updating the DID to the new PDS.")
+		}
+
+		credentials, err := agnostic.IdentityGetRecommendedDidCredentials(ctx, newClient)
+		if err != nil {
+			return addError("Could not get the recommended DID credentials from the new PDS", err)
+		}
+		var operation agnostic.IdentitySignPlcOperation_Input
+		err = json.Unmarshal(*credentials, &operation)
+		if err != nil {
+			return addError("Could not parse the recommended DID credentials", err)
+		}
+		operation.Token = plan.PLCToken.ValueStringPointer()
+
+		signedOperation, err := agnostic.IdentitySignPlcOperation(ctx, oldClient, &operation)
+		if isInvalidPLCTokenError(err) {
+			return requestPLCToken("The configured plc_token is invalid or expired, tokens can only be used once. The old PDS has emailed a new token.")
+		}
+		if err != nil {
+			return addError("Could not sign the PLC operation with the old PDS", err)
+		}
+		err = agnostic.IdentitySubmitPlcOperation(ctx, newClient, &agnostic.IdentitySubmitPlcOperation_Input{
+			Operation: signedOperation.Operation,
+		})
+		if err != nil {
+			return addError("Could not submit the PLC operation with the new PDS", err)
+		}
+	}
+	completedSteps = append(completedSteps, migrationStepIdentityUpdated)
+
+	// Activate the new account.
+	if !newStatus.Activated {
+		err = atproto.ServerActivateAccount(ctx, newClient)
+		if err != nil {
+			return addError("Could not activate the account on the new PDS", err)
+		}
+	}
+	completedSteps = append(completedSteps, migrationStepAccountActivated)
+
+	// Deactivate the old account.
+	oldStatus, err := atproto.ServerCheckAccountStatus(ctx, oldClient)
+	if err != nil {
+		return addError("Could not check the account status on the old PDS", err)
+	}
+	if oldStatus.Activated {
+		err = atproto.ServerDeactivateAccount(ctx, oldClient, &atproto.ServerDeactivateAccount_Input{})
+		if err != nil {
+			return addError("Could not deactivate the account on the old PDS", err)
+		}
+	}
+	completedSteps = append(completedSteps, migrationStepOldAccountDeactivated)
+
+	tflog.Info(ctx, "Account migration completed")
+	return diags
+}
@@ -5,11 +5,16 @@ import (
 	"crypto/rand"
 	"encoding/base64"
 	"fmt"
+	"net/http"
+	"slices"
 	"strings"
 
 	"github.com/bluesky-social/indigo/api/atproto"
+	"github.com/bluesky-social/indigo/atproto/syntax"
 	"github.com/bluesky-social/indigo/xrpc"
+	"github.com/hashicorp/terraform-plugin-framework-validators/int64validator"
 	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
 	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
@@ -17,6 +22,7 @@ import (
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
 	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
 	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
 )
 
 // Ensure the implementation satisfies the expected interfaces.
@@ -32,21 +38,34 @@ func NewAccountResource() resource.Resource {
 	return &accountResource{}
 }
 
+// importedPrivateKey marks accounts in the private state that were imported and whose password is not known yet.
+const importedPrivateKey = "imported"
+
 // accountResource is the resource implementation.
 type accountResource struct {
 	client *xrpc.Client
 }
 
 type accountResourceModel struct {
-	Did      types.String `tfsdk:"did"`
-	Email    types.String `tfsdk:"email"`
-	Handle   types.String `tfsdk:"handle"`
-	Password types.String `tfsdk:"password"`
+	Did                       types.String `tfsdk:"did"`
+	Email                     types.String `tfsdk:"email"`
+	Handle                    types.String `tfsdk:"handle"`
+	Password                  types.String `tfsdk:"password"`
+	PasswordWO                types.String `tfsdk:"password_wo"`
+	PasswordWOVersion         types.Int64  `tfsdk:"password_wo_version"`
+	GeneratedPassword         types.String `tfsdk:"generated_password"`
+	RotatePasswordTrigger     types.String `tfsdk:"rotate_password_trigger"`
+	InviteCode                types.String `tfsdk:"invite_code"`
+	HandleVerificationTimeout types.String `tfsdk:"handle_verification_timeout"`
+	HandleDNSRecordName       types.String `tfsdk:"handle_dns_record_name"`
+	HandleDNSRecordValue      types.String `tfsdk:"handle_dns_record_value"`
+	HandleWellKnownURL        types.String `tfsdk:"handle_well_known_url"`
+	HandleWellKnownBody       types.String `tfsdk:"handle_well_known_body"`
+	DeletionProtection        types.Bool   `tfsdk:"deletion_protection"`
 	// TODO to support account import:
 	//recoveryKey     types.String `tfsdk:"recovery_key"`
 
 	// These don't make sense to manage via TF:
-	//inviteCode
 	//verificationCode
 	//verificationPhone
 }
@@ -77,11 +96,72 @@ func (r *accountResource) Schema(_ context.Context, _ resource.SchemaRequest, re
 				Required:            true,
 			},
 			"password": schema.StringAttribute{
-				MarkdownDescription: "Set the initial account password on create or update the password for an existing account. If not specified on create, a password will be generated and included in the Terraform output in plaintext.",
+				MarkdownDescription: "Set the initial account password on create or update the password for an existing account. If neither this nor `password_wo` is specified on create, a password will be generated and stored in `generated_password`. For imported accounts the configured password is only recorded in the state, use `password_wo` to set a new password.",
 				Sensitive:           true,
 				Optional:            true,
 				Validators: []validator.String{
 					stringvalidator.LengthAtLeast(1),
+					stringvalidator.ConflictsWith(path.MatchRoot("password_wo")),
+				},
+			},
+			"password_wo": schema.StringAttribute{
+				MarkdownDescription: "Write-only alternative to `password` that is never stored in the Terraform state. " +
+					"Requires Terraform 1.11 or later. Changes are only applied when `password_wo_version` changes.",
+				Sensitive: true,
+				Optional:  true,
+				WriteOnly: true,
+				Validators: []validator.String{
+					stringvalidator.LengthAtLeast(1),
+				},
+			},
+			"password_wo_version": schema.Int64Attribute{
+				MarkdownDescription: "Version of `password_wo`. Increment this value to update the account password to the current value of `password_wo`.",
+				Optional:            true,
+				Validators: []validator.Int64{
+					int64validator.AlsoRequires(path.MatchRoot("password_wo")),
+				},
+			},
+			"generated_password": schema.StringAttribute{
+				MarkdownDescription: "Password generated for the account when neither `password` nor `password_wo` is specified. " +
+					"Can be read with `terraform output -json`.",
+				Computed:  true,
+				Sensitive: true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"deletion_protection": deletionProtectionAttribute("account"),
+			"handle_verification_timeout": schema.StringAttribute{
+				MarkdownDescription: "How long to wait for a custom domain `handle` to verify through DNS or HTTPS before updating it, e.g. `30m`. Defaults to `10m`.",
+				Optional:            true,
+			},
+			"handle_dns_record_name": schema.StringAttribute{
+				MarkdownDescription: "Name of the DNS TXT record that verifies a custom domain `handle`, e.g. `_atproto.alice.example.com`",
+				Computed:            true,
+			},
+			"handle_dns_record_value": schema.StringAttribute{
+				MarkdownDescription: "Value of the DNS TXT record that verifies a custom domain `handle`, e.g. `did=did:plc:...`. Known at plan time once the account exists.",
+				Computed:            true,
+			},
+			"handle_well_known_url": schema.StringAttribute{
+				MarkdownDescription: "URL that can serve `handle_well_known_body` to verify a custom domain `handle` instead of the DNS TXT record",
+				Computed:            true,
+			},
+			"handle_well_known_body": schema.StringAttribute{
+				MarkdownDescription: "Body to serve at `handle_well_known_url` to verify a custom domain `handle`. Known at plan time once the account exists.",
+				Computed:            true,
+			},
+			"invite_code": schema.StringAttribute{
+				MarkdownDescription: "Existing invite code to use when creating the account. If not specified and the PDS requires invite codes, " +
+					"a single-use code is created. Ignored after the account is created.",
+				Optional: true,
+			},
+			"rotate_password_trigger": schema.StringAttribute{
+				MarkdownDescription: "Arbitrary value that, when changed, generates a new `generated_password` and sets it on the account. " +
+					"Only applies to generated passwords, so it conflicts with `password` and `password_wo`.",
+				Optional: true,
+				Validators: []validator.String{
+					stringvalidator.ConflictsWith(path.MatchRoot("password"), path.MatchRoot("password_wo")),
 				},
 			},
 		},
@@ -97,7 +177,18 @@ func (l *accountResource) Create(ctx context.Context, req resource.CreateRequest
 		return
 	}
 
+	// Write-only attributes are only available in the config, never in the plan.
+	var passwordWO types.String
+	diags = req.Config.GetAttribute(ctx, path.Root("password_wo"), &passwordWO)
+	resp.Diagnostics.Append(diags...)
+	if diags.HasError() {
+		return
+	}
+
 	password := plan.Password.ValueString()
+	if !passwordWO.IsNull() {
+		password = passwordWO.ValueString()
+	}
 	if password == "" {
 		generatedPassword, err := getRandomPassword()
 		if err != nil {
@@ -108,42 +199,82 @@ func (l *accountResource) Create(ctx context.Context, req resource.CreateRequest
 			return
 		}
 		password = generatedPassword
+		plan.GeneratedPassword = types.StringValue(generatedPassword)
+	} else {
+		plan.GeneratedPassword = types.StringNull()
 	}
 
-	// Create an invite code
-	createInviteCodeInput := &atproto.ServerCreateInviteCode_Input{
-		UseCount: 1,
-	}
-	inviteCode, err := atproto.ServerCreateInviteCode(ctx, l.client, createInviteCodeInput)
-	if err != nil {
-		resp.Diagnostics.AddError(
-			"Error creating account",
-			"Could not create invite code, unexpected error: "+err.Error(),
-		)
-		return
+	// Mint an invite code if none was given and the PDS requires one.
+	inviteCode := plan.InviteCode.ValueString()
+	mintedInviteCode := false
+	if inviteCode == "" {
+		server, err := atproto.ServerDescribeServer(ctx, l.client)
+		if err != nil {
+			resp.Diagnostics.Append(xrpcErrorDiagnostic(
+				"Error creating account",
+				"Could not describe the PDS",
+				err,
+			))
+			return
+		}
+
+		if server.InviteCodeRequired != nil && *server.InviteCodeRequired {
+			createInviteCodeInput := &atproto.ServerCreateInviteCode_Input{
+				UseCount: 1,
+			}
+			createdInviteCode, err := atproto.ServerCreateInviteCode(ctx, l.client, createInviteCodeInput)
+			if err != nil {
+				resp.Diagnostics.Append(xrpcErrorDiagnostic(
+					"Error creating account",
+					"Could not create invite code",
+					err,
+				))
+				return
+			}
+			inviteCode = createdInviteCode.Code
+			mintedInviteCode = true
+		}
 	}
 
 	// Generate API request body from plan. Adapted from the account migration script:
 	// https://github.com/bluesky-social/indigo/blob/main/cmd/goat/account_migrate.go
 	createRecordInput := atproto.ServerCreateAccount_Input{
-		Handle:     plan.Handle.ValueString(),
-		Email:      plan.Email.ValueStringPointer(),
-		Password:   &password,
-		InviteCode: &inviteCode.Code,
+		Handle:   plan.Handle.ValueString(),
+		Email:    plan.Email.ValueStringPointer(),
+		Password: &password,
+	}
+	if inviteCode != "" {
+		createRecordInput.InviteCode = &inviteCode
 	}
 
 	// Create new account.
 	createOutput, err := atproto.ServerCreateAccount(ctx, l.client, &createRecordInput)
 	if err != nil {
-		resp.Diagnostics.AddError(
+		resp.Diagnostics.Append(xrpcErrorDiagnostic(
 			"Error creating account",
-			"Could not create account, unexpected error: "+err.Error(),
-		)
+			"Could not create account",
+			err,
+		))
+
+		// Don't leave a usable invite code behind.
+		if mintedInviteCode {
+			disableInviteCodesInput := &atproto.AdminDisableInviteCodes_Input{
+				Codes: []string{inviteCode},
+			}
+			err = atproto.AdminDisableInviteCodes(ctx, l.client, disableInviteCodesInput)
+			if err != nil {
+				resp.Diagnostics.AddWarning(
+					"Failed to disable invite code",
+					"Could not disable the invite code "+inviteCode+" created for the account, error: "+err.Error(),
+				)
+			}
+		}
 		return
 	}
 
 	// Map response body to schema and populate Computed attribute values.
 	plan.Did = types.StringValue(createOutput.Did)
+	setAccountHandleVerification(&plan)
 
 	// Set state to fully populated data.
 	diags = resp.State.Set(ctx, plan)
@@ -151,13 +282,6 @@ func (l *accountResource) Create(ctx context.Context, req resource.CreateRequest
 	if resp.Diagnostics.HasError() {
 		return
 	}
-
-	if plan.Password.ValueString() == "" {
-		resp.Diagnostics.AddWarning(
-			"Initial password created",
-			"Generated initial password for account "+plan.Handle.ValueString()+": "+password,
-		)
-	}
 }
 
 // Read refreshes the Terraform state with the latest data.
@@ -171,16 +295,22 @@ func (l *accountResource) Read(ctx context.Context, req resource.ReadRequest, re
 	}
 
 	account, err := atproto.AdminGetAccountInfo(ctx, l.client, state.Did.ValueString())
+	if isNotFoundError(err) {
+		resp.State.RemoveResource(ctx)
+		return
+	}
 	if err != nil {
-		resp.Diagnostics.AddError(
+		resp.Diagnostics.Append(xrpcErrorDiagnostic(
 			"Failed to retrieve account",
-			"Could not retrieve the account, error: "+err.Error(),
-		)
+			"Could not retrieve the account",
+			err,
+		))
 		return
 	}
 
 	state.Handle = types.StringValue(account.Handle)
-	state.Email = types.StringValue(*account.Email)
+	state.Email = types.StringPointerValue(account.Email)
+	setAccountHandleVerification(&state)
 
 	// Set refreshed state.
 	diags = resp.State.Set(ctx, &state)
@@ -216,10 +346,11 @@ func (l *accountResource) Update(ctx context.Context, req resource.UpdateRequest
 		}
 		err := atproto.AdminUpdateAccountEmail(ctx, l.client, updateEmailInput)
 		if err != nil {
-			resp.Diagnostics.AddError(
+			resp.Diagnostics.Append(xrpcErrorDiagnostic(
 				"Error updating account",
-				"Could not update account email, error: "+err.Error(),
-			)
+				"Could not update account email",
+				err,
+			))
 			return
 		}
 		state.Email = plan.Email
@@ -227,36 +358,131 @@ func (l *accountResource) Update(ctx context.Context, req resource.UpdateRequest
 
 	// update handle
 	if !strings.EqualFold(plan.Handle.ValueString(), state.Handle.ValueString()) {
+		// custom domain handles must resolve to the account before they can be set
+		server, err := atproto.ServerDescribeServer(ctx, l.client)
+		if err != nil {
+			resp.Diagnostics.Append(xrpcErrorDiagnostic(
+				"Error updating account",
+				"Could not describe the PDS",
+				err,
+			))
+			return
+		}
+		if isCustomDomainHandle(plan.Handle.ValueString(), server.AvailableUserDomains) {
+			timeout, err := handleVerificationTimeout(plan.HandleVerificationTimeout)
+			if err != nil {
+				resp.Diagnostics.AddAttributeError(
+					path.Root("handle_verification_timeout"),
+					"Invalid handle verification timeout",
+					"The handle verification timeout must be a duration such as 30m: "+err.Error(),
+				)
+				return
+			}
+			err = waitForHandle(ctx, l.client, plan.Handle.ValueString(), state.Did.ValueString(), timeout)
+			if err != nil {
+				resp.Diagnostics.AddAttributeError(
+					path.Root("handle"),
+					"Handle not verified",
+					"Could not verify the handle "+plan.Handle.ValueString()+". Create a DNS TXT record named "+handleDNSRecordName(plan.Handle.ValueString())+
+						" with the value "+handleDNSRecordValue(state.Did.ValueString())+", or serve "+state.Did.ValueString()+" at "+handleWellKnownURL(plan.Handle.ValueString())+
+						".\n\nError: "+err.Error(),
+				)
+				return
+			}
+		}
+
 		updateHandleInput := &atproto.AdminUpdateAccountHandle_Input{
 			Did:    state.Did.ValueString(),
 			Handle: plan.Handle.ValueString(),
 		}
-		err := atproto.AdminUpdateAccountHandle(ctx, l.client, updateHandleInput)
+		err = atproto.AdminUpdateAccountHandle(ctx, l.client, updateHandleInput)
 		if err != nil {
-			resp.Diagnostics.AddError(
+			resp.Diagnostics.Append(xrpcErrorDiagnostic(
 				"Error updating account",
-				"Could not update account handle, error: "+err.Error(),
-			)
+				"Could not update account handle",
+				err,
+			))
+			return
 		}
 		state.Handle = plan.Handle
 	}
 
-	// update password
-	if !strings.EqualFold(plan.Password.ValueString(), state.Password.ValueString()) {
-		updatePasswordInput := &atproto.AdminUpdateAccountPassword_Input{
-			Did:      state.Did.ValueString(),
-			Password: plan.Password.ValueString(),
+	// An imported account has no password in the state, the configured password is recorded as the current one
+	// instead of resetting the real password of the account.
+	imported, diags := req.Private.GetKey(ctx, importedPrivateKey)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	passwordUnknown := imported != nil && state.Password.IsNull()
+	if passwordUnknown && !plan.Password.IsNull() {
+		resp.Diagnostics.Append(resp.Private.SetKey(ctx, importedPrivateKey, nil)...)
+	}
+
+	// update password, passwords are case-sensitive so compare them exactly
+	if !passwordUnknown && !plan.Password.IsNull() && plan.Password.ValueString() != state.Password.ValueString() {
+		err := l.updatePassword(ctx, state.Did.ValueString(), plan.Password.ValueString())
+		if err != nil {
+			resp.Diagnostics.Append(xrpcErrorDiagnostic(
+				"Error updating account",
+				"Could not update account password",
+				err,
+			))
+			return
+		}
+	}
+	state.Password = plan.Password
+
+	// update write-only password when its version changes
+	if !plan.PasswordWOVersion.Equal(state.PasswordWOVersion) {
+		var passwordWO types.String
+		diags = req.Config.GetAttribute(ctx, path.Root("password_wo"), &passwordWO)
+		resp.Diagnostics.Append(diags...)
+		if diags.HasError() {
+			return
 		}
-		err := atproto.AdminUpdateAccountPassword(ctx, l.client, updatePasswordInput)
+
+		if !passwordWO.IsNull() {
+			err := l.updatePassword(ctx, state.Did.ValueString(), passwordWO.ValueString())
+			if err != nil {
+				resp.Diagnostics.Append(xrpcErrorDiagnostic(
+					"Error updating account",
+					"Could not update account password",
+					err,
+				))
+				return
+			}
+		}
+	}
+	state.PasswordWOVersion = plan.PasswordWOVersion
+
+	// rotate the generated password, ModifyPlan marks it unknown when the trigger changes
+	if plan.GeneratedPassword.IsUnknown() {
+		generatedPassword, err := getRandomPassword()
 		if err != nil {
 			resp.Diagnostics.AddError(
 				"Error updating account",
-				"Could not update account password, error: "+err.Error(),
+				"Failed to generate random password: "+err.Error(),
 			)
 			return
 		}
-		state.Password = plan.Password
+		err = l.updatePassword(ctx, state.Did.ValueString(), generatedPassword)
+		if err != nil {
+			resp.Diagnostics.Append(xrpcErrorDiagnostic(
+				"Error updating account",
+				"Could not update account password",
+				err,
+			))
+			return
+		}
+		plan.GeneratedPassword = types.StringValue(generatedPassword)
 	}
+	state.GeneratedPassword = plan.GeneratedPassword
+	state.RotatePasswordTrigger = plan.RotatePasswordTrigger
+	state.InviteCode = plan.InviteCode
+	state.HandleVerificationTimeout = plan.HandleVerificationTimeout
+	state.DeletionProtection = plan.DeletionProtection
+	setAccountHandleVerification(&state)
 
 	diags = resp.State.Set(ctx, state)
 	resp.Diagnostics.Append(diags...)
@@ -275,15 +501,21 @@ func (l *accountResource) Delete(ctx context.Context, req resource.DeleteRequest
 		return
 	}
 
+	if state.DeletionProtection.ValueBool() {
+		resp.Diagnostics.Append(deletionProtectionDiagnostic("account", state.Handle.ValueString()))
+		return
+	}
+
 	deleteRequest := &atproto.AdminDeleteAccount_Input{
 		Did: state.Did.ValueString(),
 	}
 	err := atproto.AdminDeleteAccount(ctx, l.client, deleteRequest)
 	if err != nil {
-		resp.Diagnostics.AddError(
+		resp.Diagnostics.Append(xrpcErrorDiagnostic(
 			"Error deleting account",
-			"Could not delete account, error: "+err.Error(),
-		)
+			"Could not delete account",
+			err,
+		))
 	}
 }
 
@@ -314,23 +546,87 @@ func (l *accountResource) Configure(_ context.Context, req resource.ConfigureReq
 		return
 	}
 
-	// Make a copy of the client without any Auth set to force the client to use the admin token from the Headers for all account requests.
-	// https://github.com/bluesky-social/indigo/issues/994
-	l.client = &xrpc.Client{
-		Host:      client.Host,
-		UserAgent: client.UserAgent,
-		Headers: map[string]string{
-			"Authorization": "Basic " + base64.StdEncoding.EncodeToString([]byte("admin:"+*client.AdminToken)),
-		},
-		AdminToken: client.AdminToken,
-		Client:     client.Client,
-		Auth:       nil,
-	}
+	l.client = newAdminClient(client)
 }
 
 func (l *accountResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
-	// Retrieve import DID and save to did attribute.
-	resource.ImportStatePassthroughID(ctx, path.Root("did"), req, resp)
+	// Resolve the import ID to a DID, accepted formats are "did:...", "handle:<handle>" and "email:<email>".
+	did, err := l.resolveAccountDid(ctx, req.ID)
+	if err != nil {
+		resp.Diagnostics.Append(xrpcErrorDiagnostic(
+			"Error importing account",
+			"Could not resolve account "+req.ID,
+			err,
+		))
+		return
+	}
+
+	// Make sure the account exists on this PDS before saving it to state.
+	account, err := atproto.AdminGetAccountInfo(ctx, l.client, did)
+	if err != nil {
+		resp.Diagnostics.Append(xrpcErrorDiagnostic(
+			"Error importing account",
+			"Could not retrieve account "+did,
+			err,
+		))
+		return
+	}
+
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("did"), account.Did)...)
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("handle"), account.Handle)...)
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("email"), account.Email)...)
+	resp.Diagnostics.Append(resp.Private.SetKey(ctx, importedPrivateKey, []byte("true"))...)
+}
+
+// resolveAccountDid returns the DID of the account identified by an import ID.
+func (l *accountResource) resolveAccountDid(ctx context.Context, id string) (string, error) {
+	switch {
+	case strings.HasPrefix(id, "handle:"):
+		handle, err := syntax.ParseHandle(strings.TrimPrefix(id, "handle:"))
+		if err != nil {
+			return "", err
+		}
+		resolved, err := atproto.IdentityResolveHandle(ctx, l.client, handle.Normalize().String())
+		if err != nil {
+			return "", err
+		}
+		return resolved.Did, nil
+
+	case strings.HasPrefix(id, "email:"):
+		email := strings.TrimPrefix(id, "email:")
+		var matches []string
+		cursor := ""
+		for {
+			accounts, err := atproto.AdminSearchAccounts(ctx, l.client, cursor, email, 100)
+			if err != nil {
+				return "", err
+			}
+			for _, account := range accounts.Accounts {
+				// searchAccounts may match partially, only accept exact matches
+				if account.Email != nil && strings.EqualFold(*account.Email, email) {
+					matches = append(matches, account.Did)
+				}
+			}
+			if accounts.Cursor == nil || *accounts.Cursor == "" || len(accounts.Accounts) == 0 {
+				break
+			}
+			cursor = *accounts.Cursor
+		}
+		if len(matches) == 0 {
+			return "", fmt.Errorf("no account found with email %s", email)
+		}
+		if len(matches) > 1 {
+			return "", fmt.Errorf("found %d accounts with email %s, import by DID instead", len(matches), email)
+		}
+		return matches[0], nil
+
+	default:
+		did, err := syntax.ParseDID(id)
+		if err != nil {
+			return "", fmt.Errorf("expected a DID, \"handle:<handle>\" or \"email:<email>\": %w", err)
+		}
+		return did.String(), nil
+	}
 }
 
 func (l *accountResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
@@ -342,13 +638,225 @@ func (l *accountResource) ModifyPlan(ctx context.Context, req resource.ModifyPla
 			return
 		}
 
-		// warn if a plaintext password will be generated during account creation
-		if req.State.Raw.IsNull() && plan.Password.ValueString() == "" {
-			resp.Diagnostics.AddWarning(
-				"Initial password not specified",
-				"Initial password for account "+plan.Handle.ValueString()+" was not specified, one will be generated and included in the Terraform output in plaintext.",
+		var state accountResourceModel
+		if !req.State.Raw.IsNull() {
+			diags = req.State.Get(ctx, &state)
+			resp.Diagnostics.Append(diags...)
+			if diags.HasError() {
+				return
+			}
+		}
+
+		if !req.State.Raw.IsNull() && state.Password.IsNull() && !plan.Password.IsNull() {
+			imported, diags := req.Private.GetKey(ctx, importedPrivateKey)
+			resp.Diagnostics.Append(diags...)
+			if imported != nil {
+				resp.Diagnostics.AddAttributeWarning(
+					path.Root("password"),
+					"Password of imported account is not changed",
+					"The account was imported, so the configured password is only recorded in the state as the current password of the account. "+
+						"To set a new password for an imported account, use password_wo and password_wo_version.",
+				)
+			}
+		}
+
+		// validate new or changed handles before anything is created
+		if !plan.Handle.IsUnknown() && !strings.EqualFold(plan.Handle.ValueString(), state.Handle.ValueString()) {
+			resp.Diagnostics.Append(l.validateHandle(ctx, plan.Handle.ValueString(), state.Did.ValueString())...)
+			if resp.Diagnostics.HasError() {
+				return
+			}
+		}
+
+		// The verification values only depend on the handle and the DID, so make them known at plan time for
+		// existing accounts.
+		if !req.State.Raw.IsNull() && !plan.Handle.IsUnknown() {
+			plan.Did = state.Did
+			setAccountHandleVerification(&plan)
+			for attribute, value := range map[string]types.String{
+				"handle_dns_record_name":  plan.HandleDNSRecordName,
+				"handle_dns_record_value": plan.HandleDNSRecordValue,
+				"handle_well_known_url":   plan.HandleWellKnownURL,
+				"handle_well_known_body":  plan.HandleWellKnownBody,
+			} {
+				resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root(attribute), value)...)
+			}
+		}
+
+		var passwordWO types.String
+		diags = req.Config.GetAttribute(ctx, path.Root("password_wo"), &passwordWO)
+		resp.Diagnostics.Append(diags...)
+		if diags.HasError() {
+			return
+		}
+
+		if plan.Password.ValueString() != "" || !passwordWO.IsNull() {
+			// an explicit password is configured, so nothing is generated
+			diags = resp.Plan.SetAttribute(ctx, path.Root("generated_password"), types.StringNull())
+			resp.Diagnostics.Append(diags...)
+			return
+		}
+
+		if !req.State.Raw.IsNull() {
+			// regenerate the password only when explicitly requested, otherwise keep the
+			// prior value, including null for imported accounts with an unknown password
+			generatedPassword := state.GeneratedPassword
+			if !plan.RotatePasswordTrigger.Equal(state.RotatePasswordTrigger) {
+				generatedPassword = types.StringUnknown()
+			}
+			diags = resp.Plan.SetAttribute(ctx, path.Root("generated_password"), generatedPassword)
+			resp.Diagnostics.Append(diags...)
+		}
+	} else if !req.State.Raw.IsNull() {
+		var state accountResourceModel
+		diags := req.State.Get(ctx, &state)
+		resp.Diagnostics.Append(diags...)
+		if diags.HasError() {
+			return
+		}
+		resp.Diagnostics.Append(l.destroyWarning(ctx, state))
+	}
+}
+
+// setAccountHandleVerification computes the handle verification attributes from the handle and DID.
+func setAccountHandleVerification(model *accountResourceModel) {
+	model.HandleDNSRecordName = types.StringValue(handleDNSRecordName(model.Handle.ValueString()))
+	model.HandleDNSRecordValue = types.StringValue(handleDNSRecordValue(model.Did.ValueString()))
+	model.HandleWellKnownURL = types.StringValue(handleWellKnownURL(model.Handle.ValueString()))
+	model.HandleWellKnownBody = types.StringValue(model.Did.ValueString())
+}
+
+// destroyWarning returns the plan-time warning for destroying the account, with the posts and follows that are lost.
+// The records are counted in the repo, since the PDS does not serve profiles to the admin.
+func (l *accountResource) destroyWarning(ctx context.Context, state accountResourceModel) diag.Diagnostic {
+	detail := "Destroying the account " + state.Handle.ValueString() + " permanently deletes its repo and cannot be undone."
+	if l.client != nil {
+		counts, err := countRecords(ctx, l.client, state.Did.ValueString(), "app.bsky.feed.post", "app.bsky.graph.follow")
+		if err != nil {
+			tflog.Warn(ctx, "Could not count the records of the account", map[string]any{"error": err.Error()})
+		} else {
+			detail = fmt.Sprintf("Destroying the account %s permanently deletes its repo with %d posts and %d follows. This cannot be undone.",
+				state.Handle.ValueString(), counts["app.bsky.feed.post"], counts["app.bsky.graph.follow"])
+		}
+	}
+	return destroyWarningDiagnostic("account", detail, state.DeletionProtection)
+}
+
+// countRecords returns the number of records in each of the collections of the repo.
+func countRecords(ctx context.Context, client *xrpc.Client, repo string, collections ...string) (map[string]int, error) {
+	description, err := atproto.RepoDescribeRepo(ctx, client, repo)
+	if err != nil {
+		return nil, err
+	}
+	counts := map[string]int{}
+	for _, collection := range collections {
+		if !slices.Contains(description.Collections, collection) {
+			continue
+		}
+		records, err := listRecords(ctx, client, repo, collection)
+		if err != nil {
+			return nil, err
+		}
+		counts[collection] = len(records)
+	}
+	return counts, nil
+}
+
+// validateHandle checks that the handle is syntactically valid, can be hosted by the PDS and is not already
+// taken by an account other than did.
+func (l *accountResource) validateHandle(ctx context.Context, handle string, did string) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	parsedHandle, err := syntax.ParseHandle(handle)
+	if err != nil {
+		diags.AddAttributeError(
+			path.Root("handle"),
+			"Invalid handle",
+			"The handle "+handle+" is not a valid handle: "+err.Error(),
+		)
+		return diags
+	}
+	handle = parsedHandle.Normalize().String()
+
+	// the client is not available yet when the provider configuration is unknown
+	if l.client == nil {
+		return diags
+	}
+
+	server, err := atproto.ServerDescribeServer(ctx, l.client)
+	if err != nil {
+		diags.AddWarning(
+			"Unable to validate handle",
+			"Could not describe the PDS to validate the handle "+handle+", error: "+err.Error(),
+		)
+		return diags
+	}
+
+	// handles on one of the server's domains must be a single label followed by the domain, anything else is
+	// treated as a custom domain
+	for _, domain := range server.AvailableUserDomains {
+		domain = strings.ToLower(domain)
+		if !strings.HasPrefix(domain, ".") {
+			domain = "." + domain
+		}
+		if strings.HasSuffix(handle, domain) && strings.Contains(strings.TrimSuffix(handle, domain), ".") {
+			diags.AddAttributeError(
+				path.Root("handle"),
+				"Invalid handle",
+				"The handle "+handle+" must be a single name followed by "+domain+", e.g. alice"+domain+".",
 			)
+			return diags
+		}
+	}
+
+	resolved, err := atproto.IdentityResolveHandle(ctx, l.client, handle)
+	if err != nil {
+		if info := parseXRPCError(err); info != nil && info.StatusCode == http.StatusBadRequest {
+			// the handle does not resolve, so it is available
+			return diags
 		}
+		diags.AddWarning(
+			"Unable to validate handle",
+			"Could not check whether the handle "+handle+" is available, error: "+err.Error(),
+		)
+		return diags
+	}
+	if resolved.Did != did {
+		diags.AddAttributeError(
+			path.Root("handle"),
+			"Handle not available",
+			"The handle "+handle+" is already in use by "+resolved.Did+".",
+		)
+	}
+
+	return diags
+}
+
+// updatePassword sets a new password for the account with the given DID.
+func (l *accountResource) updatePassword(ctx context.Context, did string, password string) error {
+	updatePasswordInput := &atproto.AdminUpdateAccountPassword_Input{
+		Did:      did,
+		Password: password,
+	}
+	return atproto.AdminUpdateAccountPassword(ctx, l.client, updatePasswordInput)
+}
+
+// newAdminClient makes a copy of the client without any Auth set to force the client to use the admin token
+// from the Headers for all account requests.
+// https://github.com/bluesky-social/indigo/issues/994
+func newAdminClient(client *xrpc.Client) *xrpc.Client {
+	headers := map[string]string{}
+	for k, v := range client.Headers {
+		headers[k] = v
+	}
+	headers["Authorization"] = "Basic " + base64.StdEncoding.EncodeToString([]byte("admin:"+*client.AdminToken))
+	return &xrpc.Client{
+		Host:       client.Host,
+		UserAgent:  client.UserAgent,
+		Headers:    headers,
+		AdminToken: client.AdminToken,
+		Client:     client.Client,
+		Auth:       nil,
 	}
 }
 
@@ -0,0 +1,34 @@
+package provider
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+func TestAccountDestroyWarning(t *testing.T) {
+	f := newFakeRepo(t)
+	for _, rkey := range []string{"post1", "post2", "post3"} {
+		f.set("app.bsky.feed.post", rkey, `{"$type":"app.bsky.feed.post","text":"Hello","createdAt":"2024-01-01T00:00:00.000Z"}`)
+	}
+	f.set("app.bsky.graph.follow", "follow1", `{"$type":"app.bsky.graph.follow","subject":"did:plc:alice","createdAt":"2024-01-01T00:00:00.000Z"}`)
+	f.set("app.bsky.actor.profile", "self", `{"$type":"app.bsky.actor.profile","displayName":"Repo"}`)
+
+	// the resource authenticates with the PDS admin password instead of a session
+	adminPassword := "admin"
+	client := f.client()
+	client.AdminToken = &adminPassword
+	l := &accountResource{client: newAdminClient(client)}
+
+	d := l.destroyWarning(context.Background(), accountResourceModel{
+		Did:                types.StringValue(testRepoDID),
+		Handle:             types.StringValue("repo.test"),
+		DeletionProtection: types.BoolValue(false),
+	})
+	if d.Severity() != diag.SeverityWarning || !strings.Contains(d.Detail(), "with 3 posts and 1 follows") {
+		t.Errorf("expected the record counts in the warning, got %v", d)
+	}
+}
@@ -0,0 +1,275 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/bluesky-social/indigo/api/atproto"
+	"github.com/bluesky-social/indigo/xrpc"
+	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// Ensure the implementation satisfies the expected interfaces.
+var (
+	_ datasource.DataSource              = &accountsDataSource{}
+	_ datasource.DataSourceWithConfigure = &accountsDataSource{}
+)
+
+// NewAccountsDataSource is a helper function to simplify the provider implementation.
+func NewAccountsDataSource() datasource.DataSource {
+	return &accountsDataSource{}
+}
+
+// accountsDataSource is the data source implementation.
+type accountsDataSource struct {
+	client *xrpc.Client
+}
+
+// accountModel represents an account hosted on the PDS.
+type accountModel struct {
+	Did             types.String   `tfsdk:"did"`
+	Handle          types.String   `tfsdk:"handle"`
+	Email           types.String   `tfsdk:"email"`
+	Status          types.String   `tfsdk:"status"`
+	Head            types.String   `tfsdk:"head"`
+	Rev             types.String   `tfsdk:"rev"`
+	IndexedAt       types.String   `tfsdk:"indexed_at"`
+	InvitedBy       types.String   `tfsdk:"invited_by"`
+	InviteNote      types.String   `tfsdk:"invite_note"`
+	InvitesDisabled types.Bool     `tfsdk:"invites_disabled"`
+	InviteCodes     []types.String `tfsdk:"invite_codes"`
+}
+
+// accountsDataSourceModel maps the data source schema data.
+type accountsDataSourceModel struct {
+	HandleSuffix types.String `tfsdk:"handle_suffix"`
+	Status       types.String `tfsdk:"status"`
+
+	Accounts []accountModel `tfsdk:"accounts"`
+}
+
+// Metadata returns the data source type name.
+func (d *accountsDataSource) Metadata(_ context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_accounts"
+}
+
+// Schema defines the schema for the data source.
+func (d *accountsDataSource) Schema(_ context.Context, _ datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "A datasource to list all accounts hosted on the PDS. This data source requires the provider to be configured with the `pds_admin_password`.",
+		Attributes: map[string]schema.Attribute{
+			"handle_suffix": schema.StringAttribute{
+				MarkdownDescription: "Only return accounts whose handle ends with this suffix, e.g. `.scoott.blog`",
+				Optional:            true,
+			},
+			"status": schema.StringAttribute{
+				MarkdownDescription: "Only return accounts with this status - must be `active`, `takendown`, `suspended`, `deactivated`, `deleted` or `inactive`. `inactive` matches accounts that are not active without reporting a more specific status.",
+				Optional:            true,
+				Validators: []validator.String{
+					stringvalidator.OneOf("active", "takendown", "suspended", "deactivated", "deleted", "inactive"),
+				},
+			},
+
+			"accounts": schema.ListNestedAttribute{
+				Computed:            true,
+				MarkdownDescription: "All accounts matching the filters",
+				NestedObject: schema.NestedAttributeObject{
+					Attributes: map[string]schema.Attribute{
+						"did": schema.StringAttribute{
+							MarkdownDescription: "Account's DID",
+							Computed:            true,
+						},
+						"handle": schema.StringAttribute{
+							MarkdownDescription: "Account's handle",
+							Computed:            true,
+						},
+						"email": schema.StringAttribute{
+							MarkdownDescription: "The email of the account",
+							Computed:            true,
+						},
+						"status": schema.StringAttribute{
+							MarkdownDescription: "Hosting status of the account, e.g. `active`, `takendown` or `deactivated`, or `inactive` if the PDS reports no specific status for an account that is not active",
+							Computed:            true,
+						},
+						"head": schema.StringAttribute{
+							MarkdownDescription: "CID of the current repo commit",
+							Computed:            true,
+						},
+						"rev": schema.StringAttribute{
+							MarkdownDescription: "Revision of the current repo commit",
+							Computed:            true,
+						},
+						"indexed_at": schema.StringAttribute{
+							MarkdownDescription: "When the account was indexed by the PDS",
+							Computed:            true,
+						},
+						"invited_by": schema.StringAttribute{
+							MarkdownDescription: "The invite code used to create the account",
+							Computed:            true,
+						},
+						"invite_note": schema.StringAttribute{
+							MarkdownDescription: "Admin note on the account's invites",
+							Computed:            true,
+						},
+						"invites_disabled": schema.BoolAttribute{
+							MarkdownDescription: "Whether the account is allowed to create invite codes",
+							Computed:            true,
+						},
+						"invite_codes": schema.ListAttribute{
+							MarkdownDescription: "Invite codes created by the account",
+							ElementType:         types.StringType,
+							Computed:            true,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// Read refreshes the Terraform state with the latest data.
+func (d *accountsDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	var data accountsDataSourceModel
+
+	// Read Terraform configuration data into the model.
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	data.Accounts = []accountModel{}
+
+	cursor := ""
+	for {
+		repos, err := atproto.SyncListRepos(ctx, d.client, cursor, 100)
+		if err != nil {
+			resp.Diagnostics.Append(xrpcErrorDiagnostic(
+				"Unable to list accounts",
+				"Could not list the repos hosted on the PDS",
+				err,
+			))
+			return
+		}
+
+		if len(repos.Repos) > 0 {
+			dids := make([]string, 0, len(repos.Repos))
+			for _, repo := range repos.Repos {
+				dids = append(dids, repo.Did)
+			}
+
+			infos, err := atproto.AdminGetAccountInfos(ctx, d.client, dids)
+			if err != nil {
+				resp.Diagnostics.Append(xrpcErrorDiagnostic(
+					"Unable to list accounts",
+					"Could not retrieve account info",
+					err,
+				))
+				return
+			}
+			accountInfos := make(map[string]*atproto.AdminDefs_AccountView, len(infos.Infos))
+			for _, info := range infos.Infos {
+				accountInfos[info.Did] = info
+			}
+
+			for _, repo := range repos.Repos {
+				account := newAccountModel(repo, accountInfos[repo.Did])
+
+				if !data.HandleSuffix.IsNull() && !strings.HasSuffix(account.Handle.ValueString(), data.HandleSuffix.ValueString()) {
+					continue
+				}
+				if !data.Status.IsNull() && account.Status.ValueString() != data.Status.ValueString() {
+					continue
+				}
+
+				data.Accounts = append(data.Accounts, account)
+			}
+		}
+
+		if repos.Cursor == nil || *repos.Cursor == "" || len(repos.Repos) == 0 {
+			break
+		}
+		cursor = *repos.Cursor
+	}
+
+	// Set state
+	diags := resp.State.Set(ctx, &data)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+}
+
+// newAccountModel maps a repo and its (optional) account info to the data source model.
+func newAccountModel(repo *atproto.SyncListRepos_Repo, info *atproto.AdminDefs_AccountView) accountModel {
+	status := "active"
+	if repo.Active != nil && !*repo.Active {
+		status = "inactive"
+		if repo.Status != nil {
+			status = *repo.Status
+		}
+	}
+
+	account := accountModel{
+		Did:             types.StringValue(repo.Did),
+		Handle:          types.StringNull(),
+		Email:           types.StringNull(),
+		Status:          types.StringValue(status),
+		Head:            types.StringValue(repo.Head),
+		Rev:             types.StringValue(repo.Rev),
+		IndexedAt:       types.StringNull(),
+		InvitedBy:       types.StringNull(),
+		InviteNote:      types.StringNull(),
+		InvitesDisabled: types.BoolNull(),
+		InviteCodes:     []types.String{},
+	}
+	if info == nil {
+		return account
+	}
+
+	account.Handle = types.StringValue(info.Handle)
+	account.Email = types.StringPointerValue(info.Email)
+	account.IndexedAt = types.StringValue(info.IndexedAt)
+	account.InviteNote = types.StringPointerValue(info.InviteNote)
+	account.InvitesDisabled = types.BoolPointerValue(info.InvitesDisabled)
+	if info.InvitedBy != nil {
+		account.InvitedBy = types.StringValue(info.InvitedBy.Code)
+	}
+	for _, invite := range info.Invites {
+		account.InviteCodes = append(account.InviteCodes, types.StringValue(invite.Code))
+	}
+	return account
+}
+
+// Configure adds the provider configured client to the data source.
+func (d *accountsDataSource) Configure(_ context.Context, req datasource.ConfigureRequest, resp *datasource.ConfigureResponse) {
+	// Add a nil check when handling ProviderData because Terraform
+	// sets that data after it calls the ConfigureProvider RPC.
+	if req.ProviderData == nil {
+		return
+	}
+
+	client, ok := req.ProviderData.(*xrpc.Client)
+	if !ok {
+		resp.Diagnostics.AddError(
+			"Unexpected Data Source Configure Type",
+			fmt.Sprintf("Expected *xrpc.Client, got: %T. Please report this issue to the provider developers.", req.ProviderData),
+		)
+
+		return
+	}
+
+	if client.AdminToken == nil {
+		resp.Diagnostics.AddError(
+			"PDSAdminPassword required",
+			"An admin token is required to list accounts, please configure the provider with the PDSAdminPassword.",
+		)
+		return
+	}
+
+	d.client = newAdminClient(client)
+}
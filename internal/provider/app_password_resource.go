@@ -0,0 +1,229 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bluesky-social/indigo/api/atproto"
+	"github.com/bluesky-social/indigo/xrpc"
+	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/boolplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// Ensure the implementation satisfies the expected interfaces.
+var (
+	_ resource.Resource              = &appPasswordResource{}
+	_ resource.ResourceWithConfigure = &appPasswordResource{}
+)
+
+// NewAppPasswordResource is a helper function to simplify the provider implementation.
+func NewAppPasswordResource() resource.Resource {
+	return &appPasswordResource{}
+}
+
+// appPasswordResource is the resource implementation.
+type appPasswordResource struct {
+	client *xrpc.Client
+}
+
+type appPasswordResourceModel struct {
+	Name       types.String `tfsdk:"name"`
+	Privileged types.Bool   `tfsdk:"privileged"`
+	Password   types.String `tfsdk:"password"`
+	CreatedAt  types.String `tfsdk:"created_at"`
+}
+
+// Metadata returns the resource type name.
+func (l *appPasswordResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_app_password"
+}
+
+// Schema defines the schema for the resource.
+func (r *appPasswordResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "Manage an app password of the account the provider is authenticated as. " +
+			"The password is only returned when the app password is created, so it cannot be imported.",
+		Attributes: map[string]schema.Attribute{
+			"name": schema.StringAttribute{
+				MarkdownDescription: "A short name for the app password, unique within the account",
+				Required:            true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+				Validators: []validator.String{
+					stringvalidator.LengthAtLeast(1),
+				},
+			},
+			"privileged": schema.BoolAttribute{
+				MarkdownDescription: "Whether the app password has access to sensitive account state, such as direct messages",
+				Optional:            true,
+				PlanModifiers: []planmodifier.Bool{
+					boolplanmodifier.RequiresReplace(),
+				},
+			},
+			"password": schema.StringAttribute{
+				MarkdownDescription: "The generated app password",
+				Computed:            true,
+				Sensitive:           true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"created_at": schema.StringAttribute{
+				MarkdownDescription: "When the app password was created",
+				Computed:            true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+		},
+	}
+}
+
+func (l *appPasswordResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	// Retrieve values from a plan.
+	var plan appPasswordResourceModel
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+	if diags.HasError() {
+		return
+	}
+
+	appPassword, err := atproto.ServerCreateAppPassword(ctx, l.client, &atproto.ServerCreateAppPassword_Input{
+		Name:       plan.Name.ValueString(),
+		Privileged: plan.Privileged.ValueBoolPointer(),
+	})
+	if err != nil {
+		resp.Diagnostics.Append(xrpcErrorDiagnostic(
+			"Error creating app password",
+			"Could not create app password "+plan.Name.ValueString(),
+			err,
+		))
+		return
+	}
+
+	plan.Password = types.StringValue(appPassword.Password)
+	plan.CreatedAt = types.StringValue(appPassword.CreatedAt)
+
+	// Set state to fully populated data.
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+}
+
+// Read refreshes the Terraform state with the latest data.
+func (l *appPasswordResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	// Get current state.
+	var state appPasswordResourceModel
+	diags := req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	appPasswords, err := atproto.ServerListAppPasswords(ctx, l.client)
+	if err != nil {
+		resp.Diagnostics.Append(xrpcErrorDiagnostic(
+			"Error reading app password",
+			"Could not list app passwords",
+			err,
+		))
+		return
+	}
+
+	// An app password with the same name but a different creation time was recreated outside of Terraform.
+	var found *atproto.ServerListAppPasswords_AppPassword
+	for _, appPassword := range appPasswords.Passwords {
+		if appPassword.Name == state.Name.ValueString() && appPassword.CreatedAt == state.CreatedAt.ValueString() {
+			found = appPassword
+			break
+		}
+	}
+	if found == nil {
+		resp.State.RemoveResource(ctx)
+		return
+	}
+
+	if found.Privileged != nil && *found.Privileged {
+		state.Privileged = types.BoolValue(true)
+	} else if !state.Privileged.IsNull() {
+		state.Privileged = types.BoolValue(false)
+	}
+
+	// Set refreshed state.
+	diags = resp.State.Set(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+}
+
+// Update is never called, every attribute change replaces the app password.
+func (l *appPasswordResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	// Retrieve values from a plan.
+	var plan appPasswordResourceModel
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+	if diags.HasError() {
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+}
+
+// Delete revokes the app password and removes the Terraform state on success.
+func (l *appPasswordResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	// Retrieve values from state.
+	var state appPasswordResourceModel
+	diags := req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	err := atproto.ServerRevokeAppPassword(ctx, l.client, &atproto.ServerRevokeAppPassword_Input{
+		Name: state.Name.ValueString(),
+	})
+	if err != nil {
+		resp.Diagnostics.Append(xrpcErrorDiagnostic(
+			"Error deleting app password",
+			"Could not revoke app password "+state.Name.ValueString(),
+			err,
+		))
+		return
+	}
+}
+
+// Configure adds the provider configured client to the resource.
+func (l *appPasswordResource) Configure(_ context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	// Add a nil check when handling ProviderData because Terraform
+	// sets that data after it calls the ConfigureProvider RPC.
+	if req.ProviderData == nil {
+		return
+	}
+
+	client, ok := req.ProviderData.(*xrpc.Client)
+
+	if !ok {
+		resp.Diagnostics.AddError(
+			"Unexpected Data Source Configure Type",
+			fmt.Sprintf("Expected *xrpc.Client, got: %T. Please report this issue to the provider developers.", req.ProviderData),
+		)
+
+		return
+	}
+
+	l.client = client
+}
@@ -0,0 +1,160 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/bluesky-social/indigo/xrpc"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+)
+
+// xrpcErrorInfo is the parsed form of an error returned by an XRPC call.
+type xrpcErrorInfo struct {
+	// Name is the error name of the response, e.g. RecordNotFound, or empty if the response had none.
+	Name       string
+	Message    string
+	StatusCode int
+	Ratelimit  *xrpc.RatelimitInfo
+}
+
+func (e *xrpcErrorInfo) String() string {
+	s := fmt.Sprintf("HTTP %d", e.StatusCode)
+	if e.Name != "" {
+		s = e.Name + " (" + s + ")"
+	}
+	if e.Message != "" {
+		s += ": " + e.Message
+	}
+	return s
+}
+
+// parseXRPCError returns the parsed XRPC error, or nil if the error is not an XRPC error response.
+func parseXRPCError(err error) *xrpcErrorInfo {
+	var xrpcErr *xrpc.Error
+	if !errors.As(err, &xrpcErr) {
+		return nil
+	}
+	info := &xrpcErrorInfo{
+		StatusCode: xrpcErr.StatusCode,
+		Ratelimit:  xrpcErr.Ratelimit,
+	}
+	var wrapped *xrpc.XRPCError
+	if errors.As(xrpcErr.Wrapped, &wrapped) {
+		info.Name = wrapped.ErrStr
+		info.Message = wrapped.Message
+	}
+	if info.StatusCode == http.StatusTooManyRequests && info.Name == "" {
+		info.Name = "RateLimitExceeded"
+	}
+	return info
+}
+
+// xrpcErrorName returns the error name of an XRPC error response, e.g. ExpiredToken, or "" for any other error.
+func xrpcErrorName(err error) string {
+	info := parseXRPCError(err)
+	if info == nil {
+		return ""
+	}
+	return info.Name
+}
+
+// isInvalidTokenError returns true if the error means the session token is expired, revoked or otherwise invalid.
+func isInvalidTokenError(err error) bool {
+	info := parseXRPCError(err)
+	if info == nil {
+		return false
+	}
+	switch info.Name {
+	case "ExpiredToken", "InvalidToken", "AuthenticationRequired":
+		return true
+	}
+	return info.StatusCode == http.StatusUnauthorized
+}
+
+// isInvalidPLCTokenError returns true if the error means the emailed PLC operation token is invalid, expired or was
+// already used. Unlike session token errors, these are reported as bad requests.
+func isInvalidPLCTokenError(err error) bool {
+	info := parseXRPCError(err)
+	if info == nil || info.StatusCode != http.StatusBadRequest {
+		return false
+	}
+	return info.Name == "InvalidToken" || info.Name == "ExpiredToken"
+}
+
+// isNotFoundError returns true if the error means the requested record or account does not exist.
+func isNotFoundError(err error) bool {
+	switch xrpcErrorName(err) {
+	case "RecordNotFound", "AccountNotFound", "RepoNotFound":
+		return true
+	}
+	return false
+}
+
+// xrpcErrorDiagnostic returns an error diagnostic for a failed XRPC call. Common errors get a specific summary,
+// attribute path and remediation, any other error is appended to the detail.
+func xrpcErrorDiagnostic(summary string, detail string, err error) diag.Diagnostic {
+	info := parseXRPCError(err)
+	if info == nil {
+		return diag.NewErrorDiagnostic(summary, detail+", error: "+err.Error())
+	}
+
+	var attributePath path.Path
+	var remediation string
+	switch info.Name {
+	case "InvalidToken", "ExpiredToken":
+		summary = "Bluesky session expired"
+		remediation = "The session of the provider is expired or was revoked. Run Terraform again to create a new session, " +
+			"or issue new session tokens if the provider is configured with access_jwt and refresh_jwt."
+	case "RecordNotFound":
+		summary = "Bluesky record not found"
+		remediation = "The record was deleted outside of Terraform. Run terraform apply to create it again, " +
+			"or remove it from the state with terraform state rm."
+	case "InvalidSwap":
+		summary = "Bluesky record changed concurrently"
+		remediation = "The record was changed outside of Terraform after it was read. Run terraform plan to review the changes, then apply again."
+	case "RateLimitExceeded":
+		summary = "Bluesky rate limit exceeded"
+		remediation = "The PDS rejected the request because too many requests were made."
+		if info.Ratelimit != nil {
+			remediation += fmt.Sprintf(" The limit is %d requests", info.Ratelimit.Limit)
+			if info.Ratelimit.Policy != "" {
+				remediation += " (policy " + info.Ratelimit.Policy + ")"
+			}
+			if !info.Ratelimit.Reset.IsZero() {
+				remediation += ", and resets at " + info.Ratelimit.Reset.Format(time.RFC3339)
+			}
+			remediation += "."
+		}
+		remediation += " Wait for the limit to reset before running Terraform again, or use session_cache_path to avoid creating a session on every run."
+	case "HandleNotAvailable":
+		summary = "Handle not available"
+		attributePath = path.Root("handle")
+		remediation = "The handle is already in use or reserved. Choose a different handle."
+	case "AccountTakedown":
+		summary = "Bluesky account taken down"
+		remediation = "The account has been taken down and cannot be managed. Contact the administrator of the PDS."
+	}
+
+	if remediation == "" {
+		detail += ", error: " + info.String()
+	} else {
+		detail += ".\n\n" + remediation + "\n\nXRPC error: " + info.String()
+	}
+	if len(attributePath.Steps()) == 0 {
+		return diag.NewErrorDiagnostic(summary, detail)
+	}
+	return diag.NewAttributeErrorDiagnostic(attributePath, summary, detail)
+}
+
+// xrpcWarningDiagnostic returns a warning diagnostic for a failed XRPC call that does not fail the operation, with the
+// same summary and remediation as xrpcErrorDiagnostic.
+func xrpcWarningDiagnostic(summary string, detail string, err error) diag.Diagnostic {
+	d := xrpcErrorDiagnostic(summary, detail, err)
+	if withPath, ok := d.(diag.DiagnosticWithPath); ok {
+		return diag.NewAttributeWarningDiagnostic(withPath.Path(), d.Summary(), d.Detail())
+	}
+	return diag.NewWarningDiagnostic(d.Summary(), d.Detail())
+}
@@ -0,0 +1,168 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bluesky-social/indigo/xrpc"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+)
+
+// testXRPCError returns the error an XRPC call returns for an error response, wrapped like the generated API wraps it.
+func testXRPCError(statusCode int, name string, message string) error {
+	err := &xrpc.Error{StatusCode: statusCode, Wrapped: &xrpc.XRPCError{ErrStr: name, Message: message}}
+	return fmt.Errorf("request failed: %w", err)
+}
+
+func TestParseXRPCError(t *testing.T) {
+	ratelimit := &xrpc.RatelimitInfo{Limit: 100}
+	for name, test := range map[string]struct {
+		err  error
+		want *xrpcErrorInfo
+	}{
+		"not an XRPC error": {err: errors.New("connection refused")},
+		"error response": {
+			err:  testXRPCError(http.StatusBadRequest, "RecordNotFound", "Could not locate record"),
+			want: &xrpcErrorInfo{Name: "RecordNotFound", Message: "Could not locate record", StatusCode: http.StatusBadRequest},
+		},
+		"no error name": {
+			err:  &xrpc.Error{StatusCode: http.StatusBadGateway, Wrapped: errors.New("bad gateway")},
+			want: &xrpcErrorInfo{StatusCode: http.StatusBadGateway},
+		},
+		"rate limited without error name": {
+			err:  &xrpc.Error{StatusCode: http.StatusTooManyRequests, Wrapped: errors.New("too many requests"), Ratelimit: ratelimit},
+			want: &xrpcErrorInfo{Name: "RateLimitExceeded", StatusCode: http.StatusTooManyRequests, Ratelimit: ratelimit},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			got := parseXRPCError(test.err)
+			if test.want == nil || got == nil {
+				if got != test.want {
+					t.Fatalf("expected %v, got %v", test.want, got)
+				}
+				return
+			}
+			if *got != *test.want {
+				t.Errorf("expected %+v, got %+v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestXRPCErrorInfoString(t *testing.T) {
+	for want, info := range map[string]*xrpcErrorInfo{
+		"HTTP 502":                     {StatusCode: http.StatusBadGateway},
+		"InvalidSwap (HTTP 400)":       {Name: "InvalidSwap", StatusCode: http.StatusBadRequest},
+		"HTTP 500: Internal error":     {Message: "Internal error", StatusCode: http.StatusInternalServerError},
+		"ExpiredToken (HTTP 400): old": {Name: "ExpiredToken", Message: "old", StatusCode: http.StatusBadRequest},
+	} {
+		if got := info.String(); got != want {
+			t.Errorf("expected %q, got %q", want, got)
+		}
+	}
+}
+
+func TestIsNotFoundError(t *testing.T) {
+	for name, test := range map[string]struct {
+		err  error
+		want bool
+	}{
+		"record not found":  {err: testXRPCError(http.StatusBadRequest, "RecordNotFound", ""), want: true},
+		"account not found": {err: testXRPCError(http.StatusBadRequest, "AccountNotFound", ""), want: true},
+		"repo not found":    {err: testXRPCError(http.StatusBadRequest, "RepoNotFound", ""), want: true},
+		"other error name":  {err: testXRPCError(http.StatusBadRequest, "InvalidRequest", "Could not find record")},
+		"not an XRPC error": {err: errors.New("RecordNotFound")},
+		"no error":          {err: nil},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if got := isNotFoundError(test.err); got != test.want {
+				t.Errorf("expected %t, got %t", test.want, got)
+			}
+		})
+	}
+}
+
+func TestXRPCErrorDiagnostic(t *testing.T) {
+	reset := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	for name, test := range map[string]struct {
+		err         error
+		wantSummary string
+		wantDetail  []string
+		wantPath    path.Path
+	}{
+		"not an XRPC error": {
+			err:         errors.New("connection refused"),
+			wantSummary: "Failed to create post",
+			wantDetail:  []string{"Could not create the post, error: connection refused"},
+		},
+		"unknown error name": {
+			err:         testXRPCError(http.StatusBadRequest, "InvalidRequest", "Invalid text"),
+			wantSummary: "Failed to create post",
+			wantDetail:  []string{"Could not create the post, error: InvalidRequest (HTTP 400): Invalid text"},
+		},
+		"expired session": {
+			err:         testXRPCError(http.StatusBadRequest, "ExpiredToken", "Token has expired"),
+			wantSummary: "Bluesky session expired",
+			wantDetail:  []string{"Could not create the post.\n\n", "Run Terraform again", "\n\nXRPC error: ExpiredToken (HTTP 400): Token has expired"},
+		},
+		"record not found": {
+			err:         testXRPCError(http.StatusBadRequest, "RecordNotFound", ""),
+			wantSummary: "Bluesky record not found",
+			wantDetail:  []string{"terraform state rm"},
+		},
+		"rate limited": {
+			err: &xrpc.Error{
+				StatusCode: http.StatusTooManyRequests,
+				Wrapped:    errors.New("too many requests"),
+				Ratelimit:  &xrpc.RatelimitInfo{Limit: 100, Policy: "100;w=300", Reset: reset},
+			},
+			wantSummary: "Bluesky rate limit exceeded",
+			wantDetail:  []string{"The limit is 100 requests (policy 100;w=300), and resets at 2025-01-01T12:00:00Z."},
+		},
+		"handle not available": {
+			err:         testXRPCError(http.StatusBadRequest, "HandleNotAvailable", "Handle already taken"),
+			wantSummary: "Handle not available",
+			wantDetail:  []string{"Choose a different handle"},
+			wantPath:    path.Root("handle"),
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			d := xrpcErrorDiagnostic("Failed to create post", "Could not create the post", test.err)
+			if d.Severity() != diag.SeverityError || d.Summary() != test.wantSummary {
+				t.Fatalf("expected the error %q, got %v", test.wantSummary, d)
+			}
+			for _, want := range test.wantDetail {
+				if !strings.Contains(d.Detail(), want) {
+					t.Errorf("expected the detail to contain %q, got %q", want, d.Detail())
+				}
+			}
+			withPath, ok := d.(diag.DiagnosticWithPath)
+			if len(test.wantPath.Steps()) == 0 {
+				if ok {
+					t.Errorf("expected no attribute path, got %s", withPath.Path())
+				}
+				return
+			}
+			if !ok || !withPath.Path().Equal(test.wantPath) {
+				t.Errorf("expected the error on %s, got %v", test.wantPath, d)
+			}
+		})
+	}
+}
+
+func TestXRPCWarningDiagnostic(t *testing.T) {
+	d := xrpcWarningDiagnostic("Unable to check server health", "Could not retrieve the PDS version", testXRPCError(http.StatusBadRequest, "ExpiredToken", ""))
+	if d.Severity() != diag.SeverityWarning || d.Summary() != "Bluesky session expired" {
+		t.Errorf("expected a session expired warning, got %v", d)
+	}
+
+	d = xrpcWarningDiagnostic("Unable to check handle", "Could not check the handle", testXRPCError(http.StatusBadRequest, "HandleNotAvailable", ""))
+	if withPath, ok := d.(diag.DiagnosticWithPath); !ok || d.Severity() != diag.SeverityWarning || !withPath.Path().Equal(path.Root("handle")) {
+		t.Errorf("expected a warning on handle, got %v", d)
+	}
+}
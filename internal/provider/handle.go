@@ -0,0 +1,90 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bluesky-social/indigo/atproto/syntax"
+	"github.com/bluesky-social/indigo/xrpc"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// defaultHandleVerificationTimeout is how long to wait for a custom domain handle to resolve when no timeout is configured.
+const defaultHandleVerificationTimeout = 10 * time.Minute
+
+// handleVerificationInterval is how long to wait between handle resolution attempts.
+const handleVerificationInterval = 10 * time.Second
+
+// handleVerificationTimeout parses the configured verification timeout, falling back to the default.
+func handleVerificationTimeout(timeout types.String) (time.Duration, error) {
+	if timeout.IsNull() || timeout.IsUnknown() {
+		return defaultHandleVerificationTimeout, nil
+	}
+	return time.ParseDuration(timeout.ValueString())
+}
+
+// isCustomDomainHandle returns true if the handle is not on one of the PDS's available user domains.
+func isCustomDomainHandle(handle string, availableUserDomains []string) bool {
+	handle = strings.ToLower(handle)
+	for _, domain := range availableUserDomains {
+		domain = strings.ToLower(domain)
+		if !strings.HasPrefix(domain, ".") {
+			domain = "." + domain
+		}
+		if strings.HasSuffix(handle, domain) {
+			return false
+		}
+	}
+	return true
+}
+
+// handleDNSRecordName returns the name of the DNS TXT record used to verify the handle.
+func handleDNSRecordName(handle string) string {
+	return "_atproto." + handle
+}
+
+// handleDNSRecordValue returns the value of the DNS TXT record used to verify a handle for the DID.
+func handleDNSRecordValue(did string) string {
+	return "did=" + did
+}
+
+// handleWellKnownURL returns the HTTPS URL used to verify the handle.
+func handleWellKnownURL(handle string) string {
+	return "https://" + handle + "/.well-known/atproto-did"
+}
+
+// waitForHandle polls DNS and HTTPS handle resolution until the handle resolves to the DID or the timeout expires.
+func waitForHandle(ctx context.Context, client *xrpc.Client, handle string, did string, timeout time.Duration) error {
+	parsedHandle, err := syntax.ParseHandle(handle)
+	if err != nil {
+		return err
+	}
+	parsedHandle = parsedHandle.Normalize()
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	directory := newIdentityDirectory(client)
+	ticker := time.NewTicker(handleVerificationInterval)
+	defer ticker.Stop()
+
+	for {
+		resolved, err := directory.ResolveHandle(ctx, parsedHandle)
+		if err == nil && resolved.String() == did {
+			return nil
+		}
+		if err == nil {
+			err = fmt.Errorf("handle resolves to %s", resolved)
+		}
+		tflog.Debug(ctx, "Waiting for handle to verify", map[string]any{"handle": parsedHandle.String(), "error": err.Error()})
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timed out after %s waiting for %s to resolve to %s, last error: %w", timeout, parsedHandle, did, err)
+		case <-ticker.C:
+		}
+	}
+}
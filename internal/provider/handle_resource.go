@@ -0,0 +1,314 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/bluesky-social/indigo/api/atproto"
+	"github.com/bluesky-social/indigo/atproto/syntax"
+	"github.com/bluesky-social/indigo/xrpc"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// Ensure the implementation satisfies the expected interfaces.
+var (
+	_ resource.Resource               = &handleResource{}
+	_ resource.ResourceWithConfigure  = &handleResource{}
+	_ resource.ResourceWithModifyPlan = &handleResource{}
+)
+
+// NewHandleResource is a helper function to simplify the provider implementation.
+func NewHandleResource() resource.Resource {
+	return &handleResource{}
+}
+
+// handleResource is the resource implementation.
+type handleResource struct {
+	client *xrpc.Client
+}
+
+type handleResourceModel struct {
+	Handle              types.String `tfsdk:"handle"`
+	Did                 types.String `tfsdk:"did"`
+	DNSRecordName       types.String `tfsdk:"dns_record_name"`
+	DNSRecordValue      types.String `tfsdk:"dns_record_value"`
+	WellKnownURL        types.String `tfsdk:"well_known_url"`
+	WellKnownBody       types.String `tfsdk:"well_known_body"`
+	VerificationTimeout types.String `tfsdk:"verification_timeout"`
+}
+
+// Metadata returns the resource type name.
+func (l *handleResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_handle"
+}
+
+// Schema defines the schema for the resource.
+func (r *handleResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "Manage the handle of the account the provider is authenticated as. " +
+			"Custom domain handles are verified through either the DNS TXT record or the HTTPS well-known file described by the computed attributes, " +
+			"which are known at plan time so they can be passed to DNS or web server resources. " +
+			"Destroying this resource does not change the account's handle.",
+		Attributes: map[string]schema.Attribute{
+			"handle": schema.StringAttribute{
+				MarkdownDescription: "The handle for the account, without the `@`",
+				Required:            true,
+			},
+			"did": schema.StringAttribute{
+				MarkdownDescription: "The DID of the account",
+				Computed:            true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"dns_record_name": schema.StringAttribute{
+				MarkdownDescription: "Name of the DNS TXT record that verifies the handle, e.g. `_atproto.alice.example.com`",
+				Computed:            true,
+			},
+			"dns_record_value": schema.StringAttribute{
+				MarkdownDescription: "Value of the DNS TXT record that verifies the handle, e.g. `did=did:plc:...`",
+				Computed:            true,
+			},
+			"well_known_url": schema.StringAttribute{
+				MarkdownDescription: "URL that can serve `well_known_body` to verify the handle instead of the DNS TXT record",
+				Computed:            true,
+			},
+			"well_known_body": schema.StringAttribute{
+				MarkdownDescription: "Body to serve at `well_known_url` to verify the handle",
+				Computed:            true,
+			},
+			"verification_timeout": schema.StringAttribute{
+				MarkdownDescription: "How long to wait for a custom domain handle to verify, e.g. `30m`. Defaults to `10m`.",
+				Optional:            true,
+			},
+		},
+	}
+}
+
+func (l *handleResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	// Retrieve values from a plan.
+	var plan handleResourceModel
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+	if diags.HasError() {
+		return
+	}
+
+	resp.Diagnostics.Append(l.updateHandle(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// Set state to fully populated data.
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+}
+
+// Read refreshes the Terraform state with the latest data.
+func (l *handleResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	// Get current state.
+	var state handleResourceModel
+	diags := req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	repo, err := atproto.RepoDescribeRepo(ctx, l.client, state.Did.ValueString())
+	if err != nil {
+		resp.Diagnostics.Append(xrpcErrorDiagnostic(
+			"Error reading handle",
+			"Could not describe the repo "+state.Did.ValueString(),
+			err,
+		))
+		return
+	}
+
+	// Keep the configured casing unless the handle was changed outside of Terraform.
+	if !strings.EqualFold(repo.Handle, state.Handle.ValueString()) {
+		state.Handle = types.StringValue(repo.Handle)
+	}
+	setHandleVerification(&state)
+
+	// Set refreshed state.
+	diags = resp.State.Set(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+}
+
+// Update updates the resource and sets the updated Terraform state on success.
+func (l *handleResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	// Retrieve values from a plan.
+	var plan handleResourceModel
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+	if diags.HasError() {
+		return
+	}
+
+	// Get current state.
+	var state handleResourceModel
+	diags = req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if !strings.EqualFold(plan.Handle.ValueString(), state.Handle.ValueString()) {
+		resp.Diagnostics.Append(l.updateHandle(ctx, &plan)...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+}
+
+// Delete removes the Terraform state, the account keeps its current handle.
+func (l *handleResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+}
+
+// Configure adds the provider configured client to the resource.
+func (l *handleResource) Configure(_ context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	// Add a nil check when handling ProviderData because Terraform
+	// sets that data after it calls the ConfigureProvider RPC.
+	if req.ProviderData == nil {
+		return
+	}
+
+	client, ok := req.ProviderData.(*xrpc.Client)
+
+	if !ok {
+		resp.Diagnostics.AddError(
+			"Unexpected Data Source Configure Type",
+			fmt.Sprintf("Expected *xrpc.Client, got: %T. Please report this issue to the provider developers.", req.ProviderData),
+		)
+
+		return
+	}
+
+	l.client = client
+}
+
+func (l *handleResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	var plan handleResourceModel
+	if req.Plan.Raw.IsNull() || l.client == nil || l.client.Auth == nil {
+		return
+	}
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+	if diags.HasError() {
+		return
+	}
+
+	if !plan.Handle.IsUnknown() {
+		_, err := syntax.ParseHandle(plan.Handle.ValueString())
+		if err != nil {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("handle"),
+				"Invalid handle",
+				"The handle "+plan.Handle.ValueString()+" is not a valid handle: "+err.Error(),
+			)
+			return
+		}
+	}
+	if _, err := handleVerificationTimeout(plan.VerificationTimeout); err != nil {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("verification_timeout"),
+			"Invalid verification timeout",
+			"The verification timeout must be a duration such as 30m: "+err.Error(),
+		)
+		return
+	}
+
+	// The verification values only depend on the handle and the authenticated DID, so make them known at plan time.
+	plan.Did = types.StringValue(l.client.Auth.Did)
+	if !plan.Handle.IsUnknown() {
+		setHandleVerification(&plan)
+	}
+
+	diags = resp.Plan.Set(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+}
+
+// updateHandle waits for a custom domain handle to verify and then sets it as the account's handle.
+func (l *handleResource) updateHandle(ctx context.Context, plan *handleResourceModel) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	plan.Did = types.StringValue(l.client.Auth.Did)
+	setHandleVerification(plan)
+
+	server, err := atproto.ServerDescribeServer(ctx, l.client)
+	if err != nil {
+		diags.Append(xrpcErrorDiagnostic(
+			"Error updating handle",
+			"Could not describe the PDS",
+			err,
+		))
+		return diags
+	}
+
+	if isCustomDomainHandle(plan.Handle.ValueString(), server.AvailableUserDomains) {
+		timeout, err := handleVerificationTimeout(plan.VerificationTimeout)
+		if err != nil {
+			diags.AddAttributeError(
+				path.Root("verification_timeout"),
+				"Invalid verification timeout",
+				"The verification timeout must be a duration such as 30m: "+err.Error(),
+			)
+			return diags
+		}
+
+		err = waitForHandle(ctx, l.client, plan.Handle.ValueString(), plan.Did.ValueString(), timeout)
+		if err != nil {
+			diags.AddAttributeError(
+				path.Root("handle"),
+				"Handle not verified",
+				"Could not verify the handle "+plan.Handle.ValueString()+". Create a DNS TXT record named "+plan.DNSRecordName.ValueString()+
+					" with the value "+plan.DNSRecordValue.ValueString()+", or serve "+plan.WellKnownBody.ValueString()+" at "+plan.WellKnownURL.ValueString()+
+					".\n\nError: "+err.Error(),
+			)
+			return diags
+		}
+	}
+
+	updateHandleInput := &atproto.IdentityUpdateHandle_Input{
+		Handle: plan.Handle.ValueString(),
+	}
+	err = atproto.IdentityUpdateHandle(ctx, l.client, updateHandleInput)
+	if err != nil {
+		diags.Append(xrpcErrorDiagnostic(
+			"Error updating handle",
+			"Could not update handle",
+			err,
+		))
+		return diags
+	}
+
+	return diags
+}
+
+// setHandleVerification computes the verification attributes from the handle and DID.
+func setHandleVerification(model *handleResourceModel) {
+	model.DNSRecordName = types.StringValue(handleDNSRecordName(model.Handle.ValueString()))
+	model.DNSRecordValue = types.StringValue(handleDNSRecordValue(model.Did.ValueString()))
+	model.WellKnownURL = types.StringValue(handleWellKnownURL(model.Handle.ValueString()))
+	model.WellKnownBody = types.StringValue(model.Did.ValueString())
+}
@@ -116,10 +116,11 @@ func (d *listDataSource) Read(ctx context.Context, req datasource.ReadRequest, r
 
 	list, err := bsky.GraphGetList(ctx, d.client, "", 50, uri)
 	if err != nil {
-		resp.Diagnostics.AddError(
+		resp.Diagnostics.Append(xrpcErrorDiagnostic(
 			"Unable to Read List",
-			err.Error(),
-		)
+			"Could not read list "+uri,
+			err,
+		))
 		return
 	}
 
@@ -143,10 +144,11 @@ func (d *listDataSource) Read(ctx context.Context, req datasource.ReadRequest, r
 	for list.Cursor != nil {
 		list, err := bsky.GraphGetList(ctx, d.client, *list.Cursor, 50, uri)
 		if err != nil {
-			resp.Diagnostics.AddError(
+			resp.Diagnostics.Append(xrpcErrorDiagnostic(
 				"Unable to Read List",
-				err.Error(),
-			)
+				"Could not read list "+uri,
+				err,
+			))
 			return
 		}
 
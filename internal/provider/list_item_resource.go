@@ -2,7 +2,11 @@ package provider
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/bluesky-social/indigo/api/atproto"
@@ -10,12 +14,15 @@ import (
 	"github.com/bluesky-social/indigo/atproto/syntax"
 	"github.com/bluesky-social/indigo/lex/util"
 	"github.com/bluesky-social/indigo/xrpc"
+	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
 	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
 	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
 )
 
 // Ensure the implementation satisfies the expected interfaces.
@@ -36,9 +43,94 @@ type listItemResource struct {
 }
 
 type listItemResourceModel struct {
-	Uri        types.String `tfsdk:"uri"`
-	ListUri    types.String `tfsdk:"list_uri"`
-	SubjectDid types.String `tfsdk:"subject_did"`
+	Uri         types.String `tfsdk:"uri"`
+	Rkey        types.String `tfsdk:"rkey"`
+	ListUri     types.String `tfsdk:"list_uri"`
+	SubjectDid  types.String `tfsdk:"subject_did"`
+	OnDuplicate types.String `tfsdk:"on_duplicate"`
+}
+
+// Values of the on_duplicate attribute.
+const (
+	onDuplicateAdopt = "adopt"
+	onDuplicateError = "error"
+)
+
+// listItemRecord is an app.bsky.graph.listitem record in a repo.
+type listItemRecord struct {
+	Uri     string `json:"-"`
+	List    string `json:"list"`
+	Subject string `json:"subject"`
+}
+
+// listItemCaches holds the listItemCache of every provider client.
+var listItemCaches sync.Map
+
+// listItemCache holds the list item records in the repo of a client by list URI. The repo is scanned once per
+// provider instance, so creating or deleting many list items does not page through the whole collection every time.
+type listItemCache struct {
+	mu    sync.Mutex
+	lists map[string][]listItemRecord
+}
+
+// listItemCacheFor returns the list item cache of the client.
+func listItemCacheFor(client *xrpc.Client) *listItemCache {
+	cache, _ := listItemCaches.LoadOrStore(client, &listItemCache{})
+	return cache.(*listItemCache)
+}
+
+// listItemRecords returns the list item records in the repo of the client that add a user to the list.
+func listItemRecords(ctx context.Context, client *xrpc.Client, listUri string) ([]listItemRecord, error) {
+	cache := listItemCacheFor(client)
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if cache.lists == nil {
+		records, err := listRecords(ctx, client, client.Auth.Did, "app.bsky.graph.listitem")
+		if err != nil {
+			return nil, err
+		}
+		lists := map[string][]listItemRecord{}
+		for _, record := range records {
+			var item listItemRecord
+			if record.Value == nil || json.Unmarshal(*record.Value, &item) != nil {
+				continue
+			}
+			item.Uri = record.Uri
+			lists[item.List] = append(lists[item.List], item)
+		}
+		cache.lists = lists
+	}
+	return slices.Clone(cache.lists[listUri]), nil
+}
+
+// addListItemRecord adds a list item written by the provider to the cache of the client.
+func addListItemRecord(client *xrpc.Client, item listItemRecord) {
+	cache := listItemCacheFor(client)
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	// The repo is not scanned yet, the scan will find the item.
+	if cache.lists == nil {
+		return
+	}
+	if slices.ContainsFunc(cache.lists[item.List], func(cached listItemRecord) bool { return cached.Uri == item.Uri }) {
+		return
+	}
+	cache.lists[item.List] = append(cache.lists[item.List], item)
+}
+
+// removeListItemRecords removes list items deleted by the provider from the cache of the client.
+func removeListItemRecords(client *xrpc.Client, uris ...syntax.ATURI) {
+	cache := listItemCacheFor(client)
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	for list, items := range cache.lists {
+		cache.lists[list] = slices.DeleteFunc(items, func(item listItemRecord) bool {
+			return slices.ContainsFunc(uris, func(uri syntax.ATURI) bool { return uri.String() == item.Uri })
+		})
+	}
 }
 
 // Metadata returns the resource type name.
@@ -65,6 +157,15 @@ func (r *listItemResource) Schema(_ context.Context, _ resource.SchemaRequest, r
 					stringplanmodifier.RequiresReplace(),
 				},
 			},
+			"on_duplicate": schema.StringAttribute{
+				MarkdownDescription: "What to do on create when the user is already on the list, e.g. because they were added in the app or by an earlier failed apply. " +
+					"`adopt` manages the existing list item instead of creating another one, and `error` fails the create. Defaults to `adopt`.",
+				Optional: true,
+				Validators: []validator.String{
+					stringvalidator.OneOf(onDuplicateAdopt, onDuplicateError),
+				},
+			},
+			"rkey": rkeyAttribute("list item"),
 			"uri": schema.StringAttribute{
 				MarkdownDescription: "Atproto URI",
 				Computed:            true, PlanModifiers: []planmodifier.String{
@@ -84,30 +185,81 @@ func (l *listItemResource) Create(ctx context.Context, req resource.CreateReques
 		return
 	}
 
+	// Look for records already adding the user to the list.
+	existing, err := listItemRecords(ctx, l.client, plan.ListUri.ValueString())
+	if err != nil {
+		resp.Diagnostics.Append(xrpcErrorDiagnostic(
+			"Error creating list item",
+			"Could not list the existing items of list "+plan.ListUri.ValueString(),
+			err,
+		))
+		return
+	}
+	var duplicates []string
+	for _, item := range existing {
+		if item.Subject == plan.SubjectDid.ValueString() {
+			duplicates = append(duplicates, item.Uri)
+		}
+	}
+	if len(duplicates) > 0 {
+		if plan.OnDuplicate.ValueString() == onDuplicateError {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("subject_did"),
+				"List item already exists",
+				"The user "+plan.SubjectDid.ValueString()+" is already on the list "+plan.ListUri.ValueString()+" with the list item "+strings.Join(duplicates, ", ")+". "+
+					"Import the existing list item with terraform import, or set on_duplicate to adopt.",
+			)
+			return
+		}
+
+		adopted := duplicates[0]
+		if !plan.Rkey.IsUnknown() {
+			adopted = ""
+			for _, uri := range duplicates {
+				if recordKey(uri).Equal(plan.Rkey) {
+					adopted = uri
+					break
+				}
+			}
+		}
+		if adopted == "" {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("rkey"),
+				"List item already exists with a different record key",
+				"The user "+plan.SubjectDid.ValueString()+" is already on the list "+plan.ListUri.ValueString()+" with the list item "+strings.Join(duplicates, ", ")+". "+
+					"Remove rkey to adopt the existing list item, or import it with terraform import.",
+			)
+			return
+		}
+
+		tflog.Info(ctx, "Adopting existing list item", map[string]any{"uri": adopted})
+		plan.Uri = types.StringValue(adopted)
+		plan.Rkey = recordKey(adopted)
+
+		diags = resp.State.Set(ctx, plan)
+		resp.Diagnostics.Append(diags...)
+		return
+	}
+
 	// Generate API request body from plan.
 	item := &bsky.GraphListitem{
 		List:      plan.ListUri.ValueString(),
 		Subject:   plan.SubjectDid.ValueString(),
 		CreatedAt: time.Now().Format(time.RFC3339),
 	}
-	createRecordInput := &atproto.RepoCreateRecord_Input{
-		Repo:       l.client.Auth.Did,
-		Collection: "app.bsky.graph.listitem",
-		Record:     &util.LexiconTypeDecoder{Val: item},
-	}
 
 	// Create new list.
-	record, err := atproto.RepoCreateRecord(ctx, l.client, createRecordInput)
-	if err != nil {
-		resp.Diagnostics.AddError(
-			"Error creating list item",
-			"Could not create list item, unexpected error: "+err.Error(),
-		)
+	uri, _, diags := createRecord(ctx, l.client, "app.bsky.graph.listitem", plan.Rkey.ValueString(), "list item", &util.LexiconTypeDecoder{Val: item})
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
 		return
 	}
 
+	addListItemRecord(l.client, listItemRecord{Uri: uri, List: item.List, Subject: item.Subject})
+
 	// Map response body to schema and populate Computed attribute values.
-	plan.Uri = types.StringValue(record.Uri)
+	plan.Uri = types.StringValue(uri)
+	plan.Rkey = recordKey(uri)
 
 	// Set state to fully populated data.
 	diags = resp.State.Set(ctx, plan)
@@ -127,51 +279,40 @@ func (l *listItemResource) Read(ctx context.Context, req resource.ReadRequest, r
 		return
 	}
 
-	var foundItem *bsky.GraphDefs_ListItemView
-
-	// Get refreshed list value from Bsky.
-	list, err := bsky.GraphGetList(ctx, l.client, "", 50, state.ListUri.ValueString())
+	// Get refreshed list item value from the repo, the app view only shows one list item per user.
+	uri, err := syntax.ParseATURI(state.Uri.ValueString())
 	if err != nil {
 		resp.Diagnostics.AddError(
-			"Unable to Read List",
-			err.Error(),
+			"Invalid list item URI",
+			"Could not parse Bluesky list item URI "+state.Uri.ValueString()+": "+err.Error(),
 		)
 		return
 	}
-
-	for _, item := range list.Items {
-		if item.Uri == state.Uri.ValueString() {
-			foundItem = item
-			break
-		}
+	record, err := atproto.RepoGetRecord(ctx, l.client, "", uri.Collection().String(), uri.Authority().String(), uri.RecordKey().String())
+	if isNotFoundError(err) {
+		resp.State.RemoveResource(ctx)
+		return
 	}
-
-	for foundItem == nil && list.Cursor != nil {
-		list, err := bsky.GraphGetList(ctx, l.client, "", 50, state.ListUri.ValueString())
-		if err != nil {
-			resp.Diagnostics.AddError(
-				"Unable to Read List",
-				err.Error(),
-			)
-			return
-		}
-
-		for _, item := range list.Items {
-			if item.Uri == state.Uri.ValueString() {
-				foundItem = item
-				break
-			}
-		}
+	if err != nil {
+		resp.Diagnostics.Append(xrpcErrorDiagnostic(
+			"Unable to Read List Item",
+			"Could not read list item "+state.Uri.ValueString(),
+			err,
+		))
+		return
 	}
-
-	if foundItem == nil {
-		resp.Diagnostics.AddError("List item not found", state.Uri.ValueString())
+	item, ok := record.Value.Val.(*bsky.GraphListitem)
+	if !ok {
+		resp.Diagnostics.AddError(
+			"Failed to parse retrieved list item",
+			"Could not cast the returned list item into the expected type",
+		)
 		return
 	}
 
-	state.Uri = types.StringValue(foundItem.Uri)
-	state.ListUri = types.StringValue(list.List.Uri)
-	state.SubjectDid = types.StringValue(foundItem.Subject.Did)
+	state.Rkey = types.StringValue(uri.RecordKey().String())
+	state.ListUri = types.StringValue(item.List)
+	state.SubjectDid = types.StringValue(item.Subject)
 
 	// Set refreshed state.
 	diags = resp.State.Set(ctx, &state)
@@ -183,7 +324,16 @@ func (l *listItemResource) Read(ctx context.Context, req resource.ReadRequest, r
 
 // Update updates the resource and sets the updated Terraform state on success.
 func (l *listItemResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
-	resp.Diagnostics.AddError("Not implemented", "List item must be replaced, not updated")
+	// Every other attribute requires replacement, and on_duplicate only applies on create, so there is nothing to write.
+	var plan listItemResourceModel
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
 }
 
 // Delete deletes the resource and removes the Terraform state on success.
@@ -212,11 +362,50 @@ func (l *listItemResource) Delete(ctx context.Context, req resource.DeleteReques
 	}
 	_, err = atproto.RepoDeleteRecord(ctx, l.client, deleteRequest)
 	if err != nil {
-		resp.Diagnostics.AddError(
+		resp.Diagnostics.Append(xrpcErrorDiagnostic(
 			"Error deleting list item",
-			"Could not delete list item, error: "+err.Error(),
-		)
+			"Could not delete list item",
+			err,
+		))
+		return
+	}
+	removeListItemRecords(l.client, uri)
+
+	// Remove any duplicate list items for the same user, otherwise the user stays on the list.
+	existing, err := listItemRecords(ctx, l.client, state.ListUri.ValueString())
+	if err != nil {
+		resp.Diagnostics.Append(xrpcErrorDiagnostic(
+			"Error deleting list item",
+			"Could not list the remaining items of list "+state.ListUri.ValueString(),
+			err,
+		))
+		return
+	}
+	var duplicates []syntax.ATURI
+	for _, item := range existing {
+		if item.Subject != state.SubjectDid.ValueString() || item.Uri == state.Uri.ValueString() {
+			continue
+		}
+		duplicate, err := syntax.ParseATURI(item.Uri)
+		if err != nil {
+			continue
+		}
+		duplicates = append(duplicates, duplicate)
+	}
+	if len(duplicates) == 0 {
+		return
+	}
+	tflog.Info(ctx, "Deleting duplicate list items", map[string]any{"count": len(duplicates)})
+	err = deleteRecords(ctx, l.client, duplicates)
+	if err != nil {
+		resp.Diagnostics.Append(xrpcErrorDiagnostic(
+			"Error deleting list item",
+			"Could not delete the duplicate list items of "+state.SubjectDid.ValueString(),
+			err,
+		))
+		return
 	}
+	removeListItemRecords(l.client, duplicates...)
 }
 
 // Configure adds the provider configured client to the resource.
@@ -0,0 +1,58 @@
+package provider
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bluesky-social/indigo/atproto/syntax"
+)
+
+func TestListItemRecordsCache(t *testing.T) {
+	f := newFakeRepo(t)
+	listUri := "at://" + testRepoDID + "/app.bsky.graph.list/list"
+	otherUri := "at://" + testRepoDID + "/app.bsky.graph.list/other"
+	for rkey, value := range map[string]string{
+		"a": `{"$type":"app.bsky.graph.listitem","list":"` + listUri + `","subject":"did:plc:alice"}`,
+		"b": `{"$type":"app.bsky.graph.listitem","list":"` + otherUri + `","subject":"did:plc:alice"}`,
+		"c": `{"$type":"app.bsky.graph.listitem","list":"` + listUri + `","subject":"did:plc:bob"}`,
+		"d": `{"$type":"app.bsky.graph.listitem","list":"` + listUri + `","subject":"did:plc:carol"}`,
+		"e": `{"$type":"app.bsky.graph.listitem","list":"` + otherUri + `","subject":"did:plc:bob"}`,
+	} {
+		f.set("app.bsky.graph.listitem", rkey, value)
+	}
+	client := f.client()
+	ctx := context.Background()
+
+	items, err := listItemRecords(ctx, client, listUri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 3 || items[0].Subject != "did:plc:alice" || items[1].Subject != "did:plc:bob" || items[2].Subject != "did:plc:carol" {
+		t.Errorf("expected the items of the list, got %+v", items)
+	}
+	if f.lists != 3 {
+		t.Errorf("expected the repo to be scanned in 3 pages, got %d", f.lists)
+	}
+
+	// Further lookups, of any list, are served from the cache and reflect the provider's own writes.
+	addListItemRecord(client, listItemRecord{Uri: "at://" + testRepoDID + "/app.bsky.graph.listitem/f", List: otherUri, Subject: "did:plc:dave"})
+	removeListItemRecords(client, syntax.ATURI("at://"+testRepoDID+"/app.bsky.graph.listitem/b"))
+	items, err = listItemRecords(ctx, client, otherUri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 || items[0].Subject != "did:plc:bob" || items[1].Subject != "did:plc:dave" {
+		t.Errorf("expected the cached items of the other list, got %+v", items)
+	}
+	if f.lists != 3 {
+		t.Errorf("expected the repo not to be scanned again, got %d pages", f.lists)
+	}
+
+	// Another provider instance scans the repo itself.
+	if _, err := listItemRecords(ctx, f.client(), listUri); err != nil {
+		t.Fatal(err)
+	}
+	if f.lists != 6 {
+		t.Errorf("expected a new scan for another client, got %d pages", f.lists)
+	}
+}
@@ -3,6 +3,7 @@ package provider
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/bluesky-social/indigo/api/atproto"
@@ -16,6 +17,7 @@ import (
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
 	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
 )
 
 // Ensure the implementation satisfies the expected interfaces.
@@ -23,6 +25,7 @@ var (
 	_ resource.Resource                = &listResource{}
 	_ resource.ResourceWithConfigure   = &listResource{}
 	_ resource.ResourceWithImportState = &listResource{}
+	_ resource.ResourceWithModifyPlan  = &listResource{}
 )
 
 // NewListResource is a helper function to simplify the provider implementation.
@@ -36,11 +39,15 @@ type listResource struct {
 }
 
 type listResourceModel struct {
-	Cid         types.String `tfsdk:"cid"`
-	Uri         types.String `tfsdk:"uri"`
-	Name        types.String `tfsdk:"name"`
-	Purpose     types.String `tfsdk:"purpose"`
-	Description types.String `tfsdk:"description"`
+	Cid                  types.String `tfsdk:"cid"`
+	Uri                  types.String `tfsdk:"uri"`
+	Rkey                 types.String `tfsdk:"rkey"`
+	Name                 types.String `tfsdk:"name"`
+	Purpose              types.String `tfsdk:"purpose"`
+	Description          types.String `tfsdk:"description"`
+	ConflictPolicy       types.String `tfsdk:"conflict_policy"`
+	DeleteItemsOnDestroy types.Bool   `tfsdk:"delete_items_on_destroy"`
+	DeletionProtection   types.Bool   `tfsdk:"deletion_protection"`
 }
 
 // Metadata returns the resource type name.
@@ -57,6 +64,7 @@ func (r *listResource) Schema(_ context.Context, _ resource.SchemaRequest, resp
 				Computed:            true,
 				MarkdownDescription: "Commit ID generated by Bluesky",
 			},
+			"rkey": rkeyAttribute("list"),
 			"uri": schema.StringAttribute{
 				Computed: true,
 				PlanModifiers: []planmodifier.String{
@@ -76,6 +84,13 @@ func (r *listResource) Schema(_ context.Context, _ resource.SchemaRequest, resp
 				Required:            true,
 				MarkdownDescription: "Description of the list",
 			},
+			"conflict_policy":     conflictPolicyAttribute("list"),
+			"deletion_protection": deletionProtectionAttribute("list"),
+			"delete_items_on_destroy": schema.BoolAttribute{
+				Optional: true,
+				MarkdownDescription: "Whether to delete the list items of the list when it is destroyed. " +
+					"Otherwise the list items stay in the repo pointing at the deleted list. Defaults to `true`.",
+			},
 		},
 	}
 }
@@ -96,25 +111,18 @@ func (l *listResource) Create(ctx context.Context, req resource.CreateRequest, r
 		Description: plan.Description.ValueStringPointer(),
 		CreatedAt:   time.Now().Format(time.RFC3339),
 	}
-	createRecordInput := &atproto.RepoCreateRecord_Input{
-		Repo:       l.client.Auth.Did,
-		Collection: "app.bsky.graph.list",
-		Record:     &util.LexiconTypeDecoder{Val: list},
-	}
 
 	// Create new list.
-	record, err := atproto.RepoCreateRecord(ctx, l.client, createRecordInput)
-	if err != nil {
-		resp.Diagnostics.AddError(
-			"Error creating list",
-			"Could not create list, unexpected error: "+err.Error(),
-		)
+	uri, cid, diags := createRecord(ctx, l.client, "app.bsky.graph.list", plan.Rkey.ValueString(), "list", &util.LexiconTypeDecoder{Val: list})
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
 		return
 	}
 
 	// Map response body to schema and populate Computed attribute values.
-	plan.Cid = types.StringValue(record.Cid)
-	plan.Uri = types.StringValue(record.Uri)
+	plan.Cid = types.StringValue(cid)
+	plan.Uri = types.StringValue(uri)
+	plan.Rkey = recordKey(uri)
 
 	// Set state to fully populated data.
 	diags = resp.State.Set(ctx, plan)
@@ -134,22 +142,49 @@ func (l *listResource) Read(ctx context.Context, req resource.ReadRequest, resp
 		return
 	}
 
-	// Get refreshed list value from Bsky.
-	list, err := bsky.GraphGetList(ctx, l.client, "", 1, state.Uri.ValueString())
+	// Get refreshed list value from the PDS, the AppView may lag behind the repo.
+	uri, err := syntax.ParseATURI(state.Uri.ValueString())
 	if err != nil {
 		resp.Diagnostics.AddError(
+			"Invalid list URI",
+			"Could not parse Bluesky list URI "+state.Uri.ValueString()+": "+err.Error(),
+		)
+		return
+	}
+	record, err := atproto.RepoGetRecord(ctx, l.client, "", uri.Collection().String(), uri.Authority().String(), uri.RecordKey().String())
+	if isNotFoundError(err) {
+		resp.State.RemoveResource(ctx)
+		return
+	}
+	if err != nil {
+		resp.Diagnostics.Append(xrpcErrorDiagnostic(
 			"Error reading list",
-			"Could not read Bsky list URI "+state.Uri.ValueString()+": "+err.Error(),
+			"Could not read Bsky list URI "+state.Uri.ValueString(),
+			err,
+		))
+		return
+	}
+	list, ok := record.Value.Val.(*bsky.GraphList)
+	if !ok {
+		resp.Diagnostics.AddError(
+			"Failed to parse retrieved list",
+			"Could not cast the returned list into the expected type",
 		)
 		return
 	}
 
 	// Overwrite with refreshed state.
-	state.Cid = types.StringValue(list.List.Cid)
-	state.Uri = types.StringValue(list.List.Uri)
-	state.Name = types.StringValue(list.List.Name)
-	state.Purpose = types.StringValue(*list.List.Purpose)
-	state.Description = types.StringValue(*list.List.Description)
+	state.Cid = types.StringPointerValue(record.Cid)
+	state.Rkey = types.StringValue(uri.RecordKey().String())
+	state.Name = types.StringValue(list.Name)
+	state.Purpose = types.StringValue("")
+	if list.Purpose != nil {
+		state.Purpose = types.StringValue(*list.Purpose)
+	}
+	state.Description = types.StringValue("")
+	if list.Description != nil {
+		state.Description = types.StringValue(*list.Description)
+	}
 
 	// Set refreshed state.
 	diags = resp.State.Set(ctx, &state)
@@ -168,8 +203,22 @@ func (l *listResource) Update(ctx context.Context, req resource.UpdateRequest, r
 	if diags.HasError() {
 		return
 	}
+	var state listResourceModel
+	diags = req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+	if diags.HasError() {
+		return
+	}
 
-	// Generate API request body from plan.
+	// Only settings such as the conflict policy or deletion protection changed, so the record is left alone.
+	if plan.Name.Equal(state.Name) && plan.Purpose.Equal(state.Purpose) && plan.Description.Equal(state.Description) {
+		plan.Cid = state.Cid
+		diags = resp.State.Set(ctx, plan)
+		resp.Diagnostics.Append(diags...)
+		return
+	}
+
+	// Apply the plan to the current list.
 	uri, err := syntax.ParseATURI(plan.Uri.ValueString())
 	if err != nil {
 		resp.Diagnostics.AddError(
@@ -178,43 +227,14 @@ func (l *listResource) Update(ctx context.Context, req resource.UpdateRequest, r
 		)
 		return
 	}
-	record, err := atproto.RepoGetRecord(ctx, l.client, "", uri.Collection().String(), uri.Authority().String(), uri.RecordKey().String())
-	if err != nil {
-		resp.Diagnostics.AddError(
-			"Failed to retrieve list",
-			"Could not retrieve the current state of the list "+plan.Uri.ValueString()+": "+err.Error(),
-		)
-		return
-	}
-	list, ok := record.Value.Val.(*bsky.GraphList)
-	if !ok {
-		resp.Diagnostics.AddError(
-			"Failed to parse retrieved list",
-			"Could not cast the returned list into the expected type",
-		)
-		return
-	}
-
-	list.Name = plan.Name.ValueString()
-	list.Purpose = plan.Purpose.ValueStringPointer()
-	list.Description = plan.Description.ValueStringPointer()
-
-	// Update existing list.
-	putRecordInput := &atproto.RepoPutRecord_Input{
-		Collection: uri.Collection().String(),
-		Repo:       uri.Authority().String(),
-		Rkey:       uri.RecordKey().String(),
-		SwapRecord: record.Cid,
-		Record: &util.LexiconTypeDecoder{
-			Val: list,
-		},
-	}
-	updatedRecord, err := atproto.RepoPutRecord(ctx, l.client, putRecordInput)
-	if err != nil {
-		resp.Diagnostics.AddError(
-			"Failed to update list",
-			"Could not update list "+plan.Uri.ValueString()+": "+err.Error(),
-		)
+	updatedRecord, diags := updateRecord(ctx, l.client, uri, state.Cid.ValueString(), plan.ConflictPolicy.ValueString(), "list", func(record map[string]any) {
+		// Only the Terraform-managed fields are changed, any other fields such as the avatar and labels are preserved.
+		record["name"] = plan.Name.ValueString()
+		record["purpose"] = plan.Purpose.ValueString()
+		setRecordDescription(record, plan.Description.ValueString())
+	})
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
 		return
 	}
 
@@ -238,7 +258,11 @@ func (l *listResource) Delete(ctx context.Context, req resource.DeleteRequest, r
 		return
 	}
 
-	// Delete existing list.
+	if state.DeletionProtection.ValueBool() {
+		resp.Diagnostics.Append(deletionProtectionDiagnostic("list", state.Uri.ValueString()))
+		return
+	}
+
 	uri, err := syntax.ParseATURI(state.Uri.ValueString())
 	if err != nil {
 		resp.Diagnostics.AddError(
@@ -247,6 +271,40 @@ func (l *listResource) Delete(ctx context.Context, req resource.DeleteRequest, r
 		)
 		return
 	}
+
+	// Delete the list items first, so the list is only deleted once no items point at it.
+	if state.DeleteItemsOnDestroy.IsNull() || state.DeleteItemsOnDestroy.ValueBool() {
+		items, err := listItemRecords(ctx, l.client, state.Uri.ValueString())
+		if err != nil {
+			resp.Diagnostics.Append(xrpcErrorDiagnostic(
+				"Error deleting list",
+				"Could not list the items of list "+state.Uri.ValueString(),
+				err,
+			))
+			return
+		}
+		itemUris := make([]syntax.ATURI, 0, len(items))
+		for _, item := range items {
+			itemUri, err := syntax.ParseATURI(item.Uri)
+			if err != nil {
+				continue
+			}
+			itemUris = append(itemUris, itemUri)
+		}
+		tflog.Info(ctx, "Deleting list items", map[string]any{"uri": state.Uri.ValueString(), "count": len(itemUris)})
+		err = deleteRecords(ctx, l.client, itemUris)
+		if err != nil {
+			resp.Diagnostics.Append(xrpcErrorDiagnostic(
+				"Error deleting list",
+				"Could not delete the items of list "+state.Uri.ValueString(),
+				err,
+			))
+			return
+		}
+		removeListItemRecords(l.client, itemUris...)
+	}
+
+	// Delete existing list.
 	deleteRequest := &atproto.RepoDeleteRecord_Input{
 		Collection: uri.Collection().String(),
 		Repo:       uri.Authority().String(),
@@ -254,13 +312,70 @@ func (l *listResource) Delete(ctx context.Context, req resource.DeleteRequest, r
 	}
 	_, err = atproto.RepoDeleteRecord(ctx, l.client, deleteRequest)
 	if err != nil {
-		resp.Diagnostics.AddError(
+		resp.Diagnostics.Append(xrpcErrorDiagnostic(
 			"Error deleting list",
-			"Could not delete list, error: "+err.Error(),
+			"Could not delete list",
+			err,
+		))
+		return
+	}
+
+	// Starter packs outside of Terraform referencing the list break once it is deleted.
+	packs, err := starterPacksForList(ctx, l.client, state.Uri.ValueString())
+	if err != nil {
+		tflog.Warn(ctx, "Could not list the starter packs referencing the list", map[string]any{"error": err.Error()})
+	} else if len(packs) > 0 {
+		resp.Diagnostics.AddWarning(
+			"List is used by starter packs",
+			"The deleted list "+state.Uri.ValueString()+" is still referenced by the starter packs "+strings.Join(packs, ", ")+". "+
+				"Delete the starter packs or change them to a different list.",
 		)
 	}
 }
 
+// ModifyPlan warns at plan time how many list items are affected when the list is destroyed or replaced.
+func (l *listResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	if req.State.Raw.IsNull() {
+		return
+	}
+	var state listResourceModel
+	diags := req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+	if diags.HasError() {
+		return
+	}
+
+	action := "Destroying"
+	if !req.Plan.Raw.IsNull() {
+		var plan listResourceModel
+		diags = req.Plan.Get(ctx, &plan)
+		resp.Diagnostics.Append(diags...)
+		if diags.HasError() {
+			return
+		}
+		// A new record key replaces the list, which deletes it like a destroy.
+		if plan.Rkey.Equal(state.Rkey) {
+			return
+		}
+		action = "Replacing"
+	}
+
+	detail := action + " the list " + state.Name.ValueString() + " (" + state.Uri.ValueString() + ") deletes it."
+	if l.client != nil {
+		items, err := listItemRecords(ctx, l.client, state.Uri.ValueString())
+		if err != nil {
+			tflog.Warn(ctx, "Could not list the items of the list", map[string]any{"error": err.Error()})
+		} else if state.DeleteItemsOnDestroy.IsNull() || state.DeleteItemsOnDestroy.ValueBool() {
+			detail = fmt.Sprintf("%s the list %s (%s) deletes it together with its %d list items.",
+				action, state.Name.ValueString(), state.Uri.ValueString(), len(items))
+		} else {
+			detail = fmt.Sprintf("%s the list %s (%s) deletes it, leaving its %d list items in the repo.",
+				action, state.Name.ValueString(), state.Uri.ValueString(), len(items))
+		}
+	}
+	resp.Diagnostics.Append(destroyWarningDiagnostic("list", detail, state.DeletionProtection))
+}
+
 // Configure adds the provider configured client to the resource.
 func (l *listResource) Configure(_ context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
 	// Add a nil check when handling ProviderData because Terraform
@@ -0,0 +1,58 @@
+package provider
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+)
+
+func TestListRead(t *testing.T) {
+	ctx := context.Background()
+	f := newFakeRepo(t)
+	uri, cid := f.set("app.bsky.graph.list", "list", `{"$type":"app.bsky.graph.list","name":"Cats","purpose":"app.bsky.graph.defs#curatelist","createdAt":"2024-01-01T00:00:00.000Z"}`)
+	l := &listResource{client: f.client()}
+
+	var schemaResp resource.SchemaResponse
+	l.Schema(ctx, resource.SchemaRequest{}, &schemaResp)
+	newState := func(uri string) tfsdk.State {
+		state := tfsdk.State{Schema: schemaResp.Schema, Raw: tftypes.NewValue(schemaResp.Schema.Type().TerraformType(ctx), nil)}
+		diags := state.Set(ctx, &listResourceModel{
+			Cid:         types.StringValue("cid-stale"),
+			Uri:         types.StringValue(uri),
+			Rkey:        types.StringValue("list"),
+			Name:        types.StringValue("Dogs"),
+			Purpose:     types.StringValue("app.bsky.graph.defs#curatelist"),
+			Description: types.StringValue("Dogs only"),
+		})
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+		return state
+	}
+
+	req := resource.ReadRequest{State: newState(uri.String())}
+	resp := resource.ReadResponse{State: req.State}
+	l.Read(ctx, req, &resp)
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("unexpected error: %v", resp.Diagnostics)
+	}
+	var model listResourceModel
+	resp.State.Get(ctx, &model)
+	if model.Cid.ValueString() != cid || model.Name.ValueString() != "Cats" || model.Description.ValueString() != "" {
+		t.Errorf("expected the record in the repo to be read, got %+v", model)
+	}
+
+	req = resource.ReadRequest{State: newState("at://" + testRepoDID + "/app.bsky.graph.list/deleted")}
+	resp = resource.ReadResponse{State: req.State}
+	l.Read(ctx, req, &resp)
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("unexpected error: %v", resp.Diagnostics)
+	}
+	if !resp.State.Raw.IsNull() {
+		t.Errorf("expected the deleted list to be removed from the state, got %v", resp.State.Raw)
+	}
+}
@@ -0,0 +1,230 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// maxLoggedBodySize is the maximum number of bytes of a request or response body that is logged.
+const maxLoggedBodySize = 64 * 1024
+
+// redacted replaces secret values in the logs.
+const redacted = "REDACTED"
+
+var (
+	// redactedHeaders are the headers that carry credentials.
+	redactedHeaders = []string{"Authorization", "Cookie", "DPoP", "Proxy-Authorization", "Set-Cookie"}
+
+	// redactedHeaderParts are the parts of header names that suggest the header carries a credential.
+	redactedHeaderParts = []string{"key", "token", "secret"}
+
+	// redactedFields are the JSON and form fields that carry credentials.
+	redactedFields = map[string]bool{
+		"accessjwt":        true,
+		"refreshjwt":       true,
+		"password":         true,
+		"authfactortoken":  true,
+		"token":            true,
+		"access_token":     true,
+		"refresh_token":    true,
+		"client_assertion": true,
+		"code":             true,
+		"code_verifier":    true,
+	}
+
+	// jwtPattern matches JWTs anywhere in a body.
+	jwtPattern = regexp.MustCompile(`eyJ[A-Za-z0-9_-]*\.[A-Za-z0-9_-]*\.[A-Za-z0-9_-]*`)
+
+	// appPasswordPattern matches app password secrets anywhere in a body.
+	appPasswordPattern = regexp.MustCompile(`\b[a-z0-9]{4}-[a-z0-9]{4}-[a-z0-9]{4}-[a-z0-9]{4}\b`)
+)
+
+// logLevelEnvVars are the environment variables setting the log level of the provider, in order of precedence.
+var logLevelEnvVars = []string{"TF_LOG_PROVIDER_BSKY", "TF_LOG_PROVIDER", "TF_LOG"}
+
+// loggingTransport logs every request with its XRPC method, status and latency at DEBUG, and the redacted
+// headers and bodies at TRACE.
+type loggingTransport struct {
+	base http.RoundTripper
+	// trace enables the TRACE logs, so the headers and bodies are only copied and redacted when they are logged.
+	trace bool
+	// headers are the names of the configured extra headers, whose values are always redacted.
+	headers []string
+}
+
+// traceLoggingEnabled returns true if Terraform shows the TRACE logs of the provider.
+func traceLoggingEnabled() bool {
+	for _, env := range logLevelEnvVars {
+		if level := os.Getenv(env); level != "" {
+			level = strings.ToUpper(level)
+			return level == "TRACE" || level == "JSON"
+		}
+	}
+	return false
+}
+
+func (t *loggingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	fields := map[string]any{
+		"http_method": req.Method,
+		"host":        req.URL.Host,
+	}
+	if nsid, ok := strings.CutPrefix(req.URL.Path, "/xrpc/"); ok {
+		fields["xrpc_method"] = nsid
+	} else {
+		fields["path"] = req.URL.Path
+	}
+
+	if t.trace {
+		tflog.Trace(ctx, "Sending HTTP request", mergeFields(fields, map[string]any{
+			"headers": redactHeaders(req.Header, t.headers),
+			"body":    requestBody(req),
+		}))
+	}
+
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	fields["latency_ms"] = time.Since(start).Milliseconds()
+	if err != nil {
+		tflog.Debug(ctx, "HTTP request failed", mergeFields(fields, map[string]any{"error": err.Error()}))
+		return resp, err
+	}
+
+	fields["status"] = resp.StatusCode
+	tflog.Debug(ctx, "HTTP request completed", fields)
+	if t.trace {
+		tflog.Trace(ctx, "Received HTTP response", mergeFields(fields, map[string]any{
+			"headers": redactHeaders(resp.Header, t.headers),
+			"body":    responseBody(resp),
+		}))
+	}
+
+	return resp, nil
+}
+
+// mergeFields returns a copy of the fields with the additional fields added.
+func mergeFields(fields map[string]any, additional map[string]any) map[string]any {
+	merged := make(map[string]any, len(fields)+len(additional))
+	for k, v := range fields {
+		merged[k] = v
+	}
+	for k, v := range additional {
+		merged[k] = v
+	}
+	return merged
+}
+
+// redactHeaders returns the headers with the credentials and the values of the configured extra headers redacted.
+func redactHeaders(header http.Header, configured []string) map[string]string {
+	headers := make(map[string]string, len(header))
+	for k := range header {
+		headers[k] = header.Get(k)
+		name := strings.ToLower(k)
+		for _, part := range redactedHeaderParts {
+			if strings.Contains(name, part) {
+				headers[k] = redacted
+			}
+		}
+	}
+	for _, names := range [][]string{redactedHeaders, configured} {
+		for _, k := range names {
+			if header.Get(k) != "" {
+				headers[http.CanonicalHeaderKey(k)] = redacted
+			}
+		}
+	}
+	return headers
+}
+
+// requestBody returns the redacted request body, read from a copy so the request is left untouched.
+func requestBody(req *http.Request) string {
+	if req.Body == nil || req.GetBody == nil {
+		return ""
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return ""
+	}
+	defer body.Close()
+	data, _ := io.ReadAll(io.LimitReader(body, maxLoggedBodySize))
+	return redactBody(req.Header.Get("Content-Type"), data)
+}
+
+// responseBody returns the redacted beginning of the response body, and puts it back in front of the unread remainder.
+func responseBody(resp *http.Response) string {
+	if !isLoggableContentType(resp.Header.Get("Content-Type")) {
+		return ""
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, maxLoggedBodySize))
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(data), resp.Body), resp.Body}
+	return redactBody(resp.Header.Get("Content-Type"), data)
+}
+
+// isLoggableContentType returns true for text bodies, binary bodies such as CAR files and blobs are not logged.
+func isLoggableContentType(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return mediaType == "application/json" || mediaType == "application/x-www-form-urlencoded" || strings.HasPrefix(mediaType, "text/")
+}
+
+// redactBody redacts the credentials in a JSON or form body, and any JWTs or app passwords in the remaining text.
+func redactBody(contentType string, data []byte) string {
+	if !isLoggableContentType(contentType) {
+		return ""
+	}
+	body := string(data)
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "application/json":
+		var value any
+		if json.Unmarshal(data, &value) == nil {
+			if redactedJSON, err := json.Marshal(redactJSON(value)); err == nil {
+				body = string(redactedJSON)
+			}
+		}
+	case "application/x-www-form-urlencoded":
+		if values, err := url.ParseQuery(body); err == nil {
+			for k := range values {
+				if redactedFields[strings.ToLower(k)] {
+					values.Set(k, redacted)
+				}
+			}
+			body = values.Encode()
+		}
+	}
+
+	body = jwtPattern.ReplaceAllString(body, redacted)
+	return appPasswordPattern.ReplaceAllString(body, redacted)
+}
+
+// redactJSON replaces the values of credential fields in a decoded JSON value.
+func redactJSON(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for k, field := range v {
+			if redactedFields[strings.ToLower(k)] {
+				v[k] = redacted
+			} else {
+				v[k] = redactJSON(field)
+			}
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = redactJSON(item)
+		}
+	}
+	return value
+}
@@ -0,0 +1,636 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bluesky-social/indigo/api/atproto"
+	"github.com/bluesky-social/indigo/atproto/syntax"
+	"github.com/bluesky-social/indigo/xrpc"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// defaultOAuthScope is the scope requested when none is configured.
+const defaultOAuthScope = "atproto transition:generic"
+
+// oauthTokenExpiryMargin is how long before the access token expires that it is refreshed.
+const oauthTokenExpiryMargin = time.Minute
+
+// oauthConfig is the OAuth confidential client configuration.
+type oauthConfig struct {
+	clientID          string
+	clientKeyID       string
+	clientKey         *ecdsa.PrivateKey
+	redirectURI       string
+	scope             string
+	authorizationCode string
+	loginHint         string
+}
+
+// oauthServerMetadata is the authorization server metadata of RFC 8414.
+type oauthServerMetadata struct {
+	Issuer                             string `json:"issuer"`
+	AuthorizationEndpoint              string `json:"authorization_endpoint"`
+	TokenEndpoint                      string `json:"token_endpoint"`
+	PushedAuthorizationRequestEndpoint string `json:"pushed_authorization_request_endpoint"`
+}
+
+// oauthSession is the cached state of an OAuth session. The DPoP key is kept with the tokens because they are bound to it.
+type oauthSession struct {
+	DPoPKey      []byte                     `json:"dpopKey"`
+	AccessToken  string                     `json:"accessToken,omitempty"`
+	RefreshToken string                     `json:"refreshToken,omitempty"`
+	ExpiresAt    time.Time                  `json:"expiresAt,omitempty"`
+	Sub          string                     `json:"sub,omitempty"`
+	Pending      *oauthPendingAuthorization `json:"pending,omitempty"`
+}
+
+// oauthPendingAuthorization is an authorization request that is waiting for the user to approve it.
+type oauthPendingAuthorization struct {
+	State        string `json:"state"`
+	CodeVerifier string `json:"codeVerifier"`
+}
+
+// oauthTokenResponse is the response of the token endpoint.
+type oauthTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+	Scope        string `json:"scope"`
+	Sub          string `json:"sub"`
+}
+
+// oauthErrorResponse is the error response of the authorization server.
+type oauthErrorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+func (e *oauthErrorResponse) String() string {
+	if e.ErrorDescription == "" {
+		return e.Error
+	}
+	return e.Error + ": " + e.ErrorDescription
+}
+
+// oauthClient authenticates XRPC requests with DPoP-bound OAuth access tokens. It is used as the
+// transport of the provider's HTTP client, and refreshes the tokens when they expire.
+type oauthClient struct {
+	config   oauthConfig
+	metadata *oauthServerMetadata
+	cache    *sessionCache
+	base     http.RoundTripper
+
+	mu              sync.Mutex
+	session         oauthSession
+	dpopKey         *ecdsa.PrivateKey
+	authServerNonce string
+	resourceNonce   string
+	// issued contains every access token of the session, so requests authenticated by other sessions are left alone.
+	issued map[string]bool
+}
+
+// loginWithOAuth authenticates the client with an OAuth session, resuming the cached session if possible.
+// Without a usable session it starts a new authorization and returns the URL the user has to open as an error.
+func loginWithOAuth(ctx context.Context, client *xrpc.Client, config oauthConfig, cache *sessionCache) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	httpClient := http.DefaultClient
+	if client.Client != nil {
+		httpClient = client.Client
+	}
+	base := httpClient.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	metadata, err := discoverOAuthServer(ctx, httpClient, client.Host)
+	if err != nil {
+		diags.AddAttributeError(
+			path.Root("pds_host"),
+			"Unable to discover OAuth authorization server",
+			"Could not read the OAuth authorization server metadata of the PDS, error: "+err.Error(),
+		)
+		return diags
+	}
+
+	oc := &oauthClient{
+		config:   config,
+		metadata: metadata,
+		cache:    cache,
+		base:     base,
+		issued:   map[string]bool{},
+	}
+
+	_, err = cache.load(&oc.session)
+	if err != nil {
+		diags.AddWarning(
+			"Unable to read Bluesky session cache",
+			"The cached OAuth session could not be read, so a new authorization will be started. Error: "+err.Error(),
+		)
+		oc.session = oauthSession{}
+	}
+	if len(oc.session.DPoPKey) > 0 {
+		key, err := x509.ParsePKCS8PrivateKey(oc.session.DPoPKey)
+		if dpopKey, ok := key.(*ecdsa.PrivateKey); err == nil && ok {
+			oc.dpopKey = dpopKey
+		}
+	}
+
+	if oc.dpopKey != nil && oc.session.RefreshToken != "" {
+		if time.Until(oc.session.ExpiresAt) < oauthTokenExpiryMargin {
+			err = oc.refresh(ctx)
+			if err != nil {
+				tflog.Debug(ctx, "Cached OAuth session could not be refreshed, starting a new authorization", map[string]any{"error": err.Error()})
+				oc.session.RefreshToken = ""
+			}
+		}
+	}
+
+	if oc.session.RefreshToken == "" && oc.session.Pending != nil && oc.dpopKey != nil && config.authorizationCode != "" {
+		err = oc.exchangeCode(ctx)
+		if err != nil {
+			diags.AddAttributeError(
+				path.Root("oauth").AtName("authorization_code"),
+				"Invalid OAuth authorization code",
+				"Could not exchange the authorization code for tokens, so a new authorization will be started. Error: "+err.Error(),
+			)
+		}
+	}
+
+	if oc.session.RefreshToken == "" {
+		authorizationURL, err := oc.startAuthorization(ctx)
+		if err != nil {
+			diags.AddError(
+				"Unable to start OAuth authorization",
+				"Could not push the authorization request to "+metadata.Issuer+", error: "+err.Error(),
+			)
+			return diags
+		}
+		diags.AddAttributeError(
+			path.Root("oauth").AtName("authorization_code"),
+			"OAuth authorization required",
+			"Open the following URL to authorize the provider:\n\n"+authorizationURL+"\n\n"+
+				"After approving, set oauth.authorization_code to the URL you are redirected to, or its code parameter, and run Terraform again.",
+		)
+		return diags
+	}
+
+	err = oc.saveSession()
+	if err != nil {
+		diags.AddWarning(
+			"Unable to write Bluesky session cache",
+			"The OAuth session could not be cached, so the next run will require a new authorization. Error: "+err.Error(),
+		)
+	}
+
+	oc.issued[oc.session.AccessToken] = true
+	client.Client = &http.Client{
+		Transport:     oc,
+		CheckRedirect: httpClient.CheckRedirect,
+		Jar:           httpClient.Jar,
+		Timeout:       httpClient.Timeout,
+	}
+	client.Auth = &xrpc.AuthInfo{
+		AccessJwt: oc.session.AccessToken,
+		Did:       oc.session.Sub,
+	}
+
+	repo, err := atproto.RepoDescribeRepo(ctx, client, oc.session.Sub)
+	if err != nil {
+		diags.Append(xrpcErrorDiagnostic(
+			"Unable to create Bluesky API client",
+			"Could not describe the repo of the authorized account "+oc.session.Sub,
+			err,
+		))
+		return diags
+	}
+	client.Auth.Handle = repo.Handle
+
+	return diags
+}
+
+// parseOAuthClientKey parses the PEM encoded P-256 private key of the client.
+func parseOAuthClientKey(keyPEM string) (*ecdsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(keyPEM))
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	var key any
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+	}
+	ecKey, ok := key.(*ecdsa.PrivateKey)
+	if !ok || ecKey.Curve != elliptic.P256() {
+		return nil, errors.New("the key must be a P-256 (ES256) private key")
+	}
+	return ecKey, nil
+}
+
+// discoverOAuthServer reads the authorization server of the PDS from its protected resource metadata.
+func discoverOAuthServer(ctx context.Context, httpClient *http.Client, pdsHost string) (*oauthServerMetadata, error) {
+	var resource struct {
+		AuthorizationServers []string `json:"authorization_servers"`
+	}
+	err := getJSON(ctx, httpClient, strings.TrimSuffix(pdsHost, "/")+"/.well-known/oauth-protected-resource", &resource)
+	if err != nil {
+		return nil, err
+	}
+	if len(resource.AuthorizationServers) == 0 {
+		return nil, errors.New("the PDS does not list any authorization servers")
+	}
+
+	issuer := strings.TrimSuffix(resource.AuthorizationServers[0], "/")
+	var metadata oauthServerMetadata
+	err = getJSON(ctx, httpClient, issuer+"/.well-known/oauth-authorization-server", &metadata)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSuffix(metadata.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("authorization server issuer %s does not match %s", metadata.Issuer, issuer)
+	}
+	if metadata.TokenEndpoint == "" || metadata.PushedAuthorizationRequestEndpoint == "" || metadata.AuthorizationEndpoint == "" {
+		return nil, fmt.Errorf("authorization server %s does not support pushed authorization requests", issuer)
+	}
+	return &metadata, nil
+}
+
+// getJSON decodes the JSON response of a GET request.
+func getJSON(ctx context.Context, httpClient *http.Client, url string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s returned HTTP status %d", url, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// startAuthorization pushes a new authorization request and returns the URL the user has to open to approve it.
+func (c *oauthClient) startAuthorization(ctx context.Context) (string, error) {
+	dpopKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return "", err
+	}
+	dpopKeyDER, err := x509.MarshalPKCS8PrivateKey(dpopKey)
+	if err != nil {
+		return "", err
+	}
+
+	pending := &oauthPendingAuthorization{
+		State:        randomToken(),
+		CodeVerifier: randomToken(),
+	}
+	challenge := sha256.Sum256([]byte(pending.CodeVerifier))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.dpopKey = dpopKey
+	c.authServerNonce = ""
+	c.session = oauthSession{
+		DPoPKey: dpopKeyDER,
+		Pending: pending,
+	}
+
+	params := url.Values{
+		"response_type":         {"code"},
+		"redirect_uri":          {c.config.redirectURI},
+		"scope":                 {c.config.scope},
+		"state":                 {pending.State},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	if c.config.loginHint != "" {
+		params.Set("login_hint", c.config.loginHint)
+	}
+	var par struct {
+		RequestURI string `json:"request_uri"`
+	}
+	err = c.authServerRequest(ctx, c.metadata.PushedAuthorizationRequestEndpoint, params, &par)
+	if err != nil {
+		return "", err
+	}
+
+	err = c.saveSessionLocked()
+	if err != nil {
+		return "", fmt.Errorf("could not cache the pending authorization: %w", err)
+	}
+
+	query := url.Values{
+		"client_id":   {c.config.clientID},
+		"request_uri": {par.RequestURI},
+	}
+	return c.metadata.AuthorizationEndpoint + "?" + query.Encode(), nil
+}
+
+// exchangeCode exchanges the authorization code of the pending authorization for tokens.
+func (c *oauthClient) exchangeCode(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	code := c.config.authorizationCode
+	// The redirect URL can be passed as is, in which case the state and issuer are checked too.
+	if redirect, err := url.Parse(code); err == nil && redirect.Query().Has("code") {
+		query := redirect.Query()
+		if query.Get("state") != c.session.Pending.State {
+			return errors.New("the redirect URL does not belong to the pending authorization, its state does not match")
+		}
+		if iss := query.Get("iss"); iss != "" && strings.TrimSuffix(iss, "/") != strings.TrimSuffix(c.metadata.Issuer, "/") {
+			return fmt.Errorf("the redirect URL was issued by %s instead of %s", iss, c.metadata.Issuer)
+		}
+		code = query.Get("code")
+	}
+
+	return c.tokenRequest(ctx, url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {c.config.redirectURI},
+		"code_verifier": {c.session.Pending.CodeVerifier},
+	})
+}
+
+// refresh replaces the tokens using the refresh token, which can only be used once.
+func (c *oauthClient) refresh(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.refreshLocked(ctx)
+}
+
+func (c *oauthClient) refreshLocked(ctx context.Context) error {
+	err := c.tokenRequest(ctx, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {c.session.RefreshToken},
+	})
+	if err != nil {
+		return err
+	}
+	return c.saveSessionLocked()
+}
+
+// tokenRequest calls the token endpoint and stores the returned tokens in the session.
+func (c *oauthClient) tokenRequest(ctx context.Context, params url.Values) error {
+	var token oauthTokenResponse
+	err := c.authServerRequest(ctx, c.metadata.TokenEndpoint, params, &token)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(token.TokenType, "DPoP") {
+		return fmt.Errorf("expected a DPoP token, got token type %s", token.TokenType)
+	}
+	if _, err := syntax.ParseDID(token.Sub); err != nil {
+		return fmt.Errorf("the token subject is not a DID: %w", err)
+	}
+	if c.session.Sub != "" && token.Sub != c.session.Sub {
+		return fmt.Errorf("the token subject %s does not match the session's account %s", token.Sub, c.session.Sub)
+	}
+	if !strings.Contains(" "+token.Scope+" ", " atproto ") {
+		return fmt.Errorf("the granted scope %q does not include atproto", token.Scope)
+	}
+
+	c.session.AccessToken = token.AccessToken
+	c.session.RefreshToken = token.RefreshToken
+	c.session.ExpiresAt = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	c.session.Sub = token.Sub
+	c.session.Pending = nil
+	c.issued[token.AccessToken] = true
+	return nil
+}
+
+// authServerRequest posts the form to the authorization server, authenticated with the client assertion and a
+// DPoP proof, and retries once if the server requires a new DPoP nonce.
+func (c *oauthClient) authServerRequest(ctx context.Context, endpoint string, params url.Values, out any) error {
+	params.Set("client_id", c.config.clientID)
+	params.Set("client_assertion_type", "urn:ietf:params:oauth:client-assertion-type:jwt-bearer")
+
+	for attempt := 0; ; attempt++ {
+		assertion, err := c.clientAssertion()
+		if err != nil {
+			return err
+		}
+		params.Set("client_assertion", assertion)
+		proof, err := c.dpopProof(http.MethodPost, endpoint, c.authServerNonce, "")
+		if err != nil {
+			return err
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(params.Encode()))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("DPoP", proof)
+
+		resp, err := c.base.RoundTrip(req)
+		if err != nil {
+			return err
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+		if nonce := resp.Header.Get("DPoP-Nonce"); nonce != "" {
+			c.authServerNonce = nonce
+		}
+
+		if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusCreated {
+			return json.Unmarshal(body, out)
+		}
+		var oauthErr oauthErrorResponse
+		if json.Unmarshal(body, &oauthErr) != nil || oauthErr.Error == "" {
+			return fmt.Errorf("POST %s returned HTTP status %d", endpoint, resp.StatusCode)
+		}
+		if oauthErr.Error == "use_dpop_nonce" && attempt == 0 {
+			continue
+		}
+		return fmt.Errorf("POST %s failed: %s", endpoint, oauthErr.String())
+	}
+}
+
+// RoundTrip authenticates requests that carry one of the session's access tokens with DPoP instead of a bearer token.
+func (c *oauthClient) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, isBearer := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+	c.mu.Lock()
+	ours := isBearer && c.issued[token]
+	c.mu.Unlock()
+	if !ours {
+		return c.base.RoundTrip(req)
+	}
+
+	for attempt := 0; ; attempt++ {
+		c.mu.Lock()
+		if time.Until(c.session.ExpiresAt) < oauthTokenExpiryMargin {
+			err := c.refreshLocked(req.Context())
+			if err != nil {
+				c.mu.Unlock()
+				return nil, fmt.Errorf("could not refresh the OAuth session: %w", err)
+			}
+		}
+		accessToken := c.session.AccessToken
+		proof, err := c.dpopProof(req.Method, req.URL.String(), c.resourceNonce, accessToken)
+		c.mu.Unlock()
+		if err != nil {
+			return nil, err
+		}
+
+		attemptReq := req.Clone(req.Context())
+		if attempt > 0 && req.Body != nil {
+			if req.GetBody == nil {
+				return nil, errors.New("cannot retry the request with a new DPoP nonce, its body cannot be replayed")
+			}
+			attemptReq.Body, err = req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+		}
+		attemptReq.Header.Set("Authorization", "DPoP "+accessToken)
+		attemptReq.Header.Set("DPoP", proof)
+
+		resp, err := c.base.RoundTrip(attemptReq)
+		if err != nil {
+			return nil, err
+		}
+		if nonce := resp.Header.Get("DPoP-Nonce"); nonce != "" {
+			c.mu.Lock()
+			c.resourceNonce = nonce
+			c.mu.Unlock()
+		}
+		if attempt == 0 && resp.StatusCode == http.StatusUnauthorized && strings.Contains(resp.Header.Get("WWW-Authenticate"), "use_dpop_nonce") {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			continue
+		}
+		return resp, nil
+	}
+}
+
+// clientAssertion returns the private_key_jwt that authenticates the client to the authorization server.
+func (c *oauthClient) clientAssertion() (string, error) {
+	now := time.Now().Unix()
+	return signES256JWT(c.config.clientKey, map[string]any{
+		"typ": "JWT",
+		"kid": c.config.clientKeyID,
+	}, map[string]any{
+		"iss": c.config.clientID,
+		"sub": c.config.clientID,
+		"aud": c.metadata.Issuer,
+		"jti": randomToken(),
+		"iat": now,
+		"exp": now + 60,
+	})
+}
+
+// dpopProof returns the DPoP proof of possession for a request. The access token is empty for authorization server requests.
+func (c *oauthClient) dpopProof(method string, requestURL string, nonce string, accessToken string) (string, error) {
+	htu, err := url.Parse(requestURL)
+	if err != nil {
+		return "", err
+	}
+	htu.RawQuery = ""
+	htu.Fragment = ""
+
+	publicKey, err := c.dpopKey.PublicKey.ECDH()
+	if err != nil {
+		return "", err
+	}
+	point := publicKey.Bytes()
+
+	claims := map[string]any{
+		"jti": randomToken(),
+		"htm": method,
+		"htu": htu.String(),
+		"iat": time.Now().Unix(),
+	}
+	if nonce != "" {
+		claims["nonce"] = nonce
+	}
+	if accessToken != "" {
+		ath := sha256.Sum256([]byte(accessToken))
+		claims["ath"] = base64.RawURLEncoding.EncodeToString(ath[:])
+	}
+	return signES256JWT(c.dpopKey, map[string]any{
+		"typ": "dpop+jwt",
+		"jwk": map[string]string{
+			"kty": "EC",
+			"crv": "P-256",
+			"x":   base64.RawURLEncoding.EncodeToString(point[1:33]),
+			"y":   base64.RawURLEncoding.EncodeToString(point[33:]),
+		},
+	}, claims)
+}
+
+// saveSession writes the session to the session cache.
+func (c *oauthClient) saveSession() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.saveSessionLocked()
+}
+
+func (c *oauthClient) saveSessionLocked() error {
+	return c.cache.save(&c.session)
+}
+
+// signES256JWT returns a JWT signed with the P-256 key.
+func signES256JWT(key *ecdsa.PrivateKey, header map[string]any, claims map[string]any) (string, error) {
+	header["alg"] = "ES256"
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	var signingInput bytes.Buffer
+	signingInput.WriteString(base64.RawURLEncoding.EncodeToString(headerJSON))
+	signingInput.WriteByte('.')
+	signingInput.WriteString(base64.RawURLEncoding.EncodeToString(claimsJSON))
+
+	digest := sha256.Sum256(signingInput.Bytes())
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	if err != nil {
+		return "", err
+	}
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+
+	return signingInput.String() + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// randomToken returns a random URL-safe string with 256 bits of entropy.
+func randomToken() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,499 @@
+package provider
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bluesky-social/indigo/xrpc"
+)
+
+const (
+	testOAuthDID         = "did:plc:testoauthaccount"
+	testOAuthClientID    = "https://client.example/client-metadata.json"
+	testOAuthRedirectURI = "https://client.example/callback"
+)
+
+// fakeOAuthServer is an authorization server and PDS that require DPoP nonces and bound tokens.
+type fakeOAuthServer struct {
+	server    *httptest.Server
+	clientKey *ecdsa.PrivateKey
+
+	mu            sync.Mutex
+	nonce         string
+	nonceRetries  map[string]int
+	state         string
+	codeChallenge string
+	accessToken   string
+	refreshToken  string
+	tokenCount    int
+	grants        []string
+	authorization []string
+}
+
+func newFakeOAuthServer(t *testing.T) *fakeOAuthServer {
+	t.Helper()
+	f := &fakeOAuthServer{
+		clientKey:    generateTestKey(t),
+		nonce:        "nonce-1",
+		nonceRetries: map[string]int{},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/oauth-protected-resource", func(w http.ResponseWriter, r *http.Request) {
+		writeTestJSON(w, http.StatusOK, map[string]any{"authorization_servers": []string{f.server.URL + "/"}})
+	})
+	mux.HandleFunc("GET /.well-known/oauth-authorization-server", func(w http.ResponseWriter, r *http.Request) {
+		writeTestJSON(w, http.StatusOK, oauthServerMetadata{
+			Issuer:                             f.server.URL,
+			AuthorizationEndpoint:              f.server.URL + "/oauth/authorize",
+			TokenEndpoint:                      f.server.URL + "/oauth/token",
+			PushedAuthorizationRequestEndpoint: f.server.URL + "/oauth/par",
+		})
+	})
+	mux.HandleFunc("POST /oauth/par", f.handlePAR)
+	mux.HandleFunc("POST /oauth/token", f.handleToken)
+	mux.HandleFunc("GET /xrpc/com.atproto.repo.describeRepo", f.handleDescribeRepo)
+	f.server = httptest.NewServer(mux)
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+// checkAuthServerRequest verifies the client assertion and DPoP proof of a request to the authorization server, and
+// asks for a nonce if the proof has none.
+func (f *fakeOAuthServer) checkAuthServerRequest(w http.ResponseWriter, r *http.Request) bool {
+	if err := r.ParseForm(); err != nil {
+		writeTestJSON(w, http.StatusBadRequest, oauthErrorResponse{Error: "invalid_request"})
+		return false
+	}
+	if r.PostForm.Get("client_id") != testOAuthClientID {
+		writeTestJSON(w, http.StatusBadRequest, oauthErrorResponse{Error: "invalid_client"})
+		return false
+	}
+	_, assertion, err := verifyTestJWT(r.PostForm.Get("client_assertion"), &f.clientKey.PublicKey)
+	if err != nil || assertion["aud"] != f.server.URL || assertion["iss"] != testOAuthClientID {
+		writeTestJSON(w, http.StatusBadRequest, oauthErrorResponse{Error: "invalid_client", ErrorDescription: "bad assertion"})
+		return false
+	}
+
+	claims, err := verifyTestDPoPProof(r.Header.Get("DPoP"))
+	if err != nil || claims["htm"] != http.MethodPost || claims["htu"] != f.server.URL+r.URL.Path {
+		writeTestJSON(w, http.StatusBadRequest, oauthErrorResponse{Error: "invalid_dpop_proof"})
+		return false
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if claims["nonce"] != f.nonce {
+		f.nonceRetries[r.URL.Path]++
+		w.Header().Set("DPoP-Nonce", f.nonce)
+		writeTestJSON(w, http.StatusBadRequest, oauthErrorResponse{Error: "use_dpop_nonce"})
+		return false
+	}
+	return true
+}
+
+func (f *fakeOAuthServer) handlePAR(w http.ResponseWriter, r *http.Request) {
+	if !f.checkAuthServerRequest(w, r) {
+		return
+	}
+	if r.PostForm.Get("code_challenge_method") != "S256" || r.PostForm.Get("redirect_uri") != testOAuthRedirectURI {
+		writeTestJSON(w, http.StatusBadRequest, oauthErrorResponse{Error: "invalid_request"})
+		return
+	}
+	f.mu.Lock()
+	f.state = r.PostForm.Get("state")
+	f.codeChallenge = r.PostForm.Get("code_challenge")
+	f.mu.Unlock()
+	writeTestJSON(w, http.StatusCreated, map[string]any{"request_uri": "urn:ietf:params:oauth:request_uri:test", "expires_in": 60})
+}
+
+func (f *fakeOAuthServer) handleToken(w http.ResponseWriter, r *http.Request) {
+	if !f.checkAuthServerRequest(w, r) {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	grant := r.PostForm.Get("grant_type")
+	f.grants = append(f.grants, grant)
+	switch grant {
+	case "authorization_code":
+		verifier := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		if r.PostForm.Get("code") != "test-code" || base64.RawURLEncoding.EncodeToString(verifier[:]) != f.codeChallenge {
+			writeTestJSON(w, http.StatusBadRequest, oauthErrorResponse{Error: "invalid_grant"})
+			return
+		}
+	case "refresh_token":
+		if r.PostForm.Get("refresh_token") != f.refreshToken {
+			writeTestJSON(w, http.StatusBadRequest, oauthErrorResponse{Error: "invalid_grant", ErrorDescription: "refresh token replayed"})
+			return
+		}
+	default:
+		writeTestJSON(w, http.StatusBadRequest, oauthErrorResponse{Error: "unsupported_grant_type"})
+		return
+	}
+	f.tokenCount++
+	f.accessToken = "access-" + strconv.Itoa(f.tokenCount)
+	f.refreshToken = "refresh-" + strconv.Itoa(f.tokenCount)
+	writeTestJSON(w, http.StatusOK, oauthTokenResponse{
+		AccessToken:  f.accessToken,
+		TokenType:    "DPoP",
+		ExpiresIn:    3600,
+		RefreshToken: f.refreshToken,
+		Scope:        defaultOAuthScope,
+		Sub:          testOAuthDID,
+	})
+}
+
+func (f *fakeOAuthServer) handleDescribeRepo(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	authorization := r.Header.Get("Authorization")
+	f.authorization = append(f.authorization, authorization)
+	if authorization != "DPoP "+f.accessToken {
+		writeTestJSON(w, http.StatusUnauthorized, map[string]string{"error": "InvalidToken"})
+		return
+	}
+	claims, err := verifyTestDPoPProof(r.Header.Get("DPoP"))
+	ath := sha256.Sum256([]byte(f.accessToken))
+	if err != nil || claims["htm"] != http.MethodGet || claims["htu"] != f.server.URL+r.URL.Path || claims["ath"] != base64.RawURLEncoding.EncodeToString(ath[:]) {
+		writeTestJSON(w, http.StatusBadRequest, map[string]string{"error": "InvalidDPoPProof"})
+		return
+	}
+	if claims["nonce"] != f.nonce {
+		f.nonceRetries[r.URL.Path]++
+		w.Header().Set("DPoP-Nonce", f.nonce)
+		w.Header().Set("WWW-Authenticate", `DPoP error="use_dpop_nonce"`)
+		writeTestJSON(w, http.StatusUnauthorized, map[string]string{"error": "use_dpop_nonce"})
+		return
+	}
+	writeTestJSON(w, http.StatusOK, map[string]any{
+		"handle":          "alice.test",
+		"did":             testOAuthDID,
+		"didDoc":          map[string]any{},
+		"collections":     []string{},
+		"handleIsCorrect": true,
+	})
+}
+
+func (f *fakeOAuthServer) config(authorizationCode string) oauthConfig {
+	return oauthConfig{
+		clientID:          testOAuthClientID,
+		clientKeyID:       "test-key",
+		clientKey:         f.clientKey,
+		redirectURI:       testOAuthRedirectURI,
+		scope:             defaultOAuthScope,
+		authorizationCode: authorizationCode,
+	}
+}
+
+func TestLoginWithOAuth(t *testing.T) {
+	f := newFakeOAuthServer(t)
+	cache := &sessionCache{
+		dir:      t.TempDir(),
+		pdsHost:  f.server.URL,
+		handle:   "oauth " + testOAuthClientID + " alice.test",
+		password: "client key",
+	}
+	ctx := context.Background()
+
+	// Without a session the login pushes an authorization request and asks the user to approve it.
+	client := &xrpc.Client{Host: f.server.URL, Client: f.server.Client()}
+	diags := loginWithOAuth(ctx, client, f.config(""), cache)
+	if !diags.HasError() || diags[0].Summary() != "OAuth authorization required" {
+		t.Fatalf("expected the authorization to be required, got %v", diags)
+	}
+	if !strings.Contains(diags[0].Detail(), f.server.URL+"/oauth/authorize?") ||
+		!strings.Contains(diags[0].Detail(), "request_uri=urn%3Aietf%3Aparams%3Aoauth%3Arequest_uri%3Atest") {
+		t.Errorf("expected the authorization URL in the error, got %q", diags[0].Detail())
+	}
+	if f.nonceRetries["/oauth/par"] != 1 {
+		t.Errorf("expected the pushed authorization request to be retried once with a nonce, got %d retries", f.nonceRetries["/oauth/par"])
+	}
+
+	// The redirect URL resumes the pending authorization from the cache and exchanges the code.
+	redirect := testOAuthRedirectURI + "?" + url.Values{
+		"code":  {"test-code"},
+		"state": {f.state},
+		"iss":   {f.server.URL},
+	}.Encode()
+	client = &xrpc.Client{Host: f.server.URL, Client: f.server.Client()}
+	diags = loginWithOAuth(ctx, client, f.config(redirect), cache)
+	if diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+	if client.Auth.Did != testOAuthDID || client.Auth.Handle != "alice.test" || client.Auth.AccessJwt != "access-1" {
+		t.Errorf("unexpected auth info: %+v", client.Auth)
+	}
+	if len(f.grants) != 1 || f.grants[0] != "authorization_code" {
+		t.Errorf("expected a single code exchange, got %v", f.grants)
+	}
+	if f.nonceRetries["/xrpc/com.atproto.repo.describeRepo"] != 1 {
+		t.Errorf("expected the PDS request to be retried once with a nonce, got %d retries", f.nonceRetries["/xrpc/com.atproto.repo.describeRepo"])
+	}
+	for _, authorization := range f.authorization {
+		if authorization != "DPoP access-1" {
+			t.Errorf("expected the PDS request to use the DPoP access token, got %q", authorization)
+		}
+	}
+
+	// The cached session is reused without a new authorization.
+	client = &xrpc.Client{Host: f.server.URL, Client: f.server.Client()}
+	diags = loginWithOAuth(ctx, client, f.config(""), cache)
+	if diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+	if len(f.grants) != 1 {
+		t.Errorf("expected the cached session to be reused, got grants %v", f.grants)
+	}
+}
+
+func TestLoginWithOAuthStateMismatch(t *testing.T) {
+	f := newFakeOAuthServer(t)
+	cache := &sessionCache{dir: t.TempDir(), pdsHost: f.server.URL, handle: "oauth", password: "client key"}
+	ctx := context.Background()
+
+	client := &xrpc.Client{Host: f.server.URL, Client: f.server.Client()}
+	loginWithOAuth(ctx, client, f.config(""), cache)
+
+	redirect := testOAuthRedirectURI + "?code=test-code&state=other"
+	diags := loginWithOAuth(ctx, client, f.config(redirect), cache)
+	if !diags.HasError() || diags[0].Summary() != "Invalid OAuth authorization code" {
+		t.Fatalf("expected the redirect URL to be rejected, got %v", diags)
+	}
+	if len(f.grants) != 0 {
+		t.Errorf("expected no code exchange, got %v", f.grants)
+	}
+}
+
+func TestOAuthClientRoundTrip(t *testing.T) {
+	f := newFakeOAuthServer(t)
+	f.refreshToken = "refresh-0"
+	metadata, err := discoverOAuthServer(context.Background(), f.server.Client(), f.server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oc := &oauthClient{
+		config:   f.config(""),
+		metadata: metadata,
+		cache:    &sessionCache{dir: t.TempDir(), pdsHost: f.server.URL, handle: "oauth", password: "client key"},
+		base:     http.DefaultTransport,
+		dpopKey:  generateTestKey(t),
+		session: oauthSession{
+			AccessToken:  "access-0",
+			RefreshToken: "refresh-0",
+			ExpiresAt:    time.Now().Add(-time.Minute),
+			Sub:          testOAuthDID,
+		},
+		issued: map[string]bool{"access-0": true},
+	}
+	client := &xrpc.Client{
+		Host:   f.server.URL,
+		Client: &http.Client{Transport: oc},
+		Auth:   &xrpc.AuthInfo{AccessJwt: "access-0", Did: testOAuthDID},
+	}
+
+	// The expired access token is refreshed and the bearer token replaced by the DPoP-bound one.
+	var out map[string]any
+	err = client.Do(context.Background(), xrpc.Query, "", "com.atproto.repo.describeRepo", map[string]any{"repo": testOAuthDID}, nil, &out)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(f.grants) != 1 || f.grants[0] != "refresh_token" {
+		t.Errorf("expected a single refresh, got %v", f.grants)
+	}
+	if got := f.authorization[len(f.authorization)-1]; got != "DPoP access-1" {
+		t.Errorf("expected the refreshed DPoP access token, got %q", got)
+	}
+	if oc.session.RefreshToken != "refresh-1" {
+		t.Errorf("expected the refresh token to be replaced, got %q", oc.session.RefreshToken)
+	}
+
+	// Bearer tokens not issued to the session are sent unchanged.
+	f.authorization = nil
+	client.Auth.AccessJwt = "foreign"
+	_ = client.Do(context.Background(), xrpc.Query, "", "com.atproto.repo.describeRepo", map[string]any{"repo": testOAuthDID}, nil, &out)
+	if len(f.authorization) != 1 || f.authorization[0] != "Bearer foreign" {
+		t.Errorf("expected the foreign bearer token to be left alone, got %v", f.authorization)
+	}
+}
+
+func TestDiscoverOAuthServerIssuerMismatch(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/oauth-protected-resource":
+			writeTestJSON(w, http.StatusOK, map[string]any{"authorization_servers": []string{server.URL}})
+		default:
+			writeTestJSON(w, http.StatusOK, oauthServerMetadata{
+				Issuer:                             "https://other.example",
+				AuthorizationEndpoint:              server.URL + "/oauth/authorize",
+				TokenEndpoint:                      server.URL + "/oauth/token",
+				PushedAuthorizationRequestEndpoint: server.URL + "/oauth/par",
+			})
+		}
+	}))
+	defer server.Close()
+
+	_, err := discoverOAuthServer(context.Background(), server.Client(), server.URL)
+	if err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Errorf("expected an issuer mismatch error, got %v", err)
+	}
+}
+
+func TestSignES256JWT(t *testing.T) {
+	key := generateTestKey(t)
+	token, err := signES256JWT(key, map[string]any{"typ": "JWT", "kid": "key-1"}, map[string]any{"iss": "client", "exp": 42})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	header, claims, err := verifyTestJWT(token, &key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header["alg"] != "ES256" || header["typ"] != "JWT" || header["kid"] != "key-1" {
+		t.Errorf("unexpected header: %v", header)
+	}
+	if claims["iss"] != "client" || claims["exp"] != float64(42) {
+		t.Errorf("unexpected claims: %v", claims)
+	}
+
+	if _, _, err := verifyTestJWT(token, &generateTestKey(t).PublicKey); err == nil {
+		t.Error("expected the signature not to verify with another key")
+	}
+}
+
+func TestDPoPProof(t *testing.T) {
+	oc := &oauthClient{dpopKey: generateTestKey(t)}
+
+	proof, err := oc.dpopProof(http.MethodGet, "https://pds.example/xrpc/com.atproto.repo.getRecord?repo=did%3Aplc%3Aa#fragment", "nonce-1", "access-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := verifyTestDPoPProof(proof)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ath := sha256.Sum256([]byte("access-token"))
+	want := map[string]any{
+		"htm":   http.MethodGet,
+		"htu":   "https://pds.example/xrpc/com.atproto.repo.getRecord",
+		"nonce": "nonce-1",
+		"ath":   base64.RawURLEncoding.EncodeToString(ath[:]),
+	}
+	for k, v := range want {
+		if claims[k] != v {
+			t.Errorf("expected claim %s to be %v, got %v", k, v, claims[k])
+		}
+	}
+	if claims["jti"] == "" || claims["iat"] == nil {
+		t.Errorf("expected jti and iat claims, got %v", claims)
+	}
+
+	header, _, _ := decodeTestJWT(proof)
+	jwk := header["jwk"].(map[string]any)
+	if x, _ := base64.RawURLEncoding.DecodeString(jwk["x"].(string)); new(big.Int).SetBytes(x).Cmp(oc.dpopKey.X) != 0 {
+		t.Error("expected the JWK to contain the public DPoP key")
+	}
+
+	proof, err = oc.dpopProof(http.MethodPost, "https://auth.example/oauth/token", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err = verifyTestDPoPProof(proof)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := claims["nonce"]; ok {
+		t.Errorf("expected no nonce claim, got %v", claims)
+	}
+	if _, ok := claims["ath"]; ok {
+		t.Errorf("expected no ath claim for authorization server requests, got %v", claims)
+	}
+}
+
+func generateTestKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func writeTestJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(value)
+}
+
+// decodeTestJWT decodes the header and claims of a JWT without verifying it.
+func decodeTestJWT(token string) (map[string]any, map[string]any, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, nil, fmt.Errorf("expected a JWT with three parts, got %q", token)
+	}
+	var header, claims map[string]any
+	for i, out := range []*map[string]any{&header, &claims} {
+		data, err := base64.RawURLEncoding.DecodeString(parts[i])
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := json.Unmarshal(data, out); err != nil {
+			return nil, nil, err
+		}
+	}
+	return header, claims, nil
+}
+
+// verifyTestJWT verifies the ES256 signature of a JWT with the public key and returns its header and claims.
+func verifyTestJWT(token string, key *ecdsa.PublicKey) (map[string]any, map[string]any, error) {
+	header, claims, err := decodeTestJWT(token)
+	if err != nil {
+		return nil, nil, err
+	}
+	parts := strings.Split(token, ".")
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || len(signature) != 64 {
+		return nil, nil, fmt.Errorf("expected a 64 byte signature, got %d bytes", len(signature))
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if !ecdsa.Verify(key, digest[:], new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])) {
+		return nil, nil, errors.New("the JWT signature does not verify")
+	}
+	return header, claims, nil
+}
+
+// verifyTestDPoPProof verifies a DPoP proof with the public key in its header and returns its claims.
+func verifyTestDPoPProof(proof string) (map[string]any, error) {
+	header, _, err := decodeTestJWT(proof)
+	if err != nil {
+		return nil, err
+	}
+	if header["typ"] != "dpop+jwt" {
+		return nil, fmt.Errorf("unexpected DPoP proof type %v", header["typ"])
+	}
+	jwk, ok := header["jwk"].(map[string]any)
+	if !ok || jwk["kty"] != "EC" || jwk["crv"] != "P-256" {
+		return nil, fmt.Errorf("unexpected DPoP proof key %v", header["jwk"])
+	}
+	x, _ := base64.RawURLEncoding.DecodeString(fmt.Sprint(jwk["x"]))
+	y, _ := base64.RawURLEncoding.DecodeString(fmt.Sprint(jwk["y"]))
+	key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+	_, claims, err := verifyTestJWT(proof, key)
+	return claims, err
+}
@@ -0,0 +1,483 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/bluesky-social/indigo/api/agnostic"
+	"github.com/bluesky-social/indigo/api/atproto"
+	"github.com/bluesky-social/indigo/atproto/crypto"
+	"github.com/bluesky-social/indigo/xrpc"
+	"github.com/hashicorp/terraform-plugin-framework-validators/listvalidator"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// Ensure the implementation satisfies the expected interfaces.
+var (
+	_ resource.Resource                   = &plcRotationKeysResource{}
+	_ resource.ResourceWithConfigure      = &plcRotationKeysResource{}
+	_ resource.ResourceWithValidateConfig = &plcRotationKeysResource{}
+)
+
+// defaultPLCDirectoryURL is the PLC directory used when none is configured.
+const defaultPLCDirectoryURL = "https://plc.directory"
+
+// maxPLCRotationKeys is the maximum number of rotation keys allowed by the PLC directory.
+const maxPLCRotationKeys = 5
+
+// NewPLCRotationKeysResource is a helper function to simplify the provider implementation.
+func NewPLCRotationKeysResource() resource.Resource {
+	return &plcRotationKeysResource{}
+}
+
+// plcRotationKeysResource is the resource implementation.
+type plcRotationKeysResource struct {
+	client *xrpc.Client
+}
+
+type plcRotationKeysResourceModel struct {
+	Did                   types.String `tfsdk:"did"`
+	RotationKeys          types.List   `tfsdk:"rotation_keys"`
+	IncludePDSRotationKey types.Bool   `tfsdk:"include_pds_rotation_key"`
+	PLCToken              types.String `tfsdk:"plc_token"`
+	PLCDirectoryURL       types.String `tfsdk:"plc_directory_url"`
+	ActiveRotationKeys    types.List   `tfsdk:"active_rotation_keys"`
+	LastOperationCid      types.String `tfsdk:"last_operation_cid"`
+	LastOperationAt       types.String `tfsdk:"last_operation_at"`
+}
+
+// plcAuditEntry is an entry of a DID's audit log in the PLC directory.
+type plcAuditEntry struct {
+	Did       string `json:"did"`
+	Cid       string `json:"cid"`
+	Nullified bool   `json:"nullified"`
+	CreatedAt string `json:"createdAt"`
+	Operation struct {
+		Type         string   `json:"type"`
+		RotationKeys []string `json:"rotationKeys"`
+	} `json:"operation"`
+}
+
+// Metadata returns the resource type name.
+func (l *plcRotationKeysResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_plc_rotation_keys"
+}
+
+// Schema defines the schema for the resource.
+func (r *plcRotationKeysResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "Manage the rotation keys of the did:plc identity the provider is authenticated as. " +
+			"The PLC operation is signed by the PDS, which requires a token that the PDS emails to the account. " +
+			"Destroying this resource leaves the rotation keys unchanged.",
+		Attributes: map[string]schema.Attribute{
+			"did": schema.StringAttribute{
+				MarkdownDescription: "The DID of the identity",
+				Computed:            true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"rotation_keys": schema.ListAttribute{
+				MarkdownDescription: "Rotation keys in `did:key` format, in order of priority",
+				ElementType:         types.StringType,
+				Required:            true,
+				Validators: []validator.List{
+					listvalidator.SizeBetween(1, maxPLCRotationKeys),
+					listvalidator.UniqueValues(),
+				},
+			},
+			"include_pds_rotation_key": schema.BoolAttribute{
+				MarkdownDescription: "Append the PDS's recommended rotation key after `rotation_keys`, so the PDS can keep updating the identity. Defaults to `true`.",
+				Optional:            true,
+			},
+			"plc_token": schema.StringAttribute{
+				MarkdownDescription: "Write-only token emailed by the PDS to authorize signing the PLC operation. The token can only be used once and is never stored in the Terraform state. " +
+					"If not specified or no longer valid when the rotation keys need to be updated, a new token is requested and the apply fails until it is set. " +
+					"Requires Terraform 1.11 or later.",
+				Optional:  true,
+				Sensitive: true,
+				WriteOnly: true,
+			},
+			"plc_directory_url": schema.StringAttribute{
+				MarkdownDescription: "Base URL of the PLC directory to read the audit log from. Defaults to `" + defaultPLCDirectoryURL + "`.",
+				Optional:            true,
+			},
+			"active_rotation_keys": schema.ListAttribute{
+				MarkdownDescription: "Rotation keys of the latest operation in the PLC directory's audit log",
+				ElementType:         types.StringType,
+				Computed:            true,
+			},
+			"last_operation_cid": schema.StringAttribute{
+				MarkdownDescription: "CID of the latest operation in the PLC directory's audit log",
+				Computed:            true,
+			},
+			"last_operation_at": schema.StringAttribute{
+				MarkdownDescription: "When the latest operation was created",
+				Computed:            true,
+			},
+		},
+	}
+}
+
+// ValidateConfig checks that the rotation keys are valid did:key values.
+func (l *plcRotationKeysResource) ValidateConfig(ctx context.Context, req resource.ValidateConfigRequest, resp *resource.ValidateConfigResponse) {
+	var config plcRotationKeysResourceModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &config)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// The keys can come from another resource, in which case they are only known during apply.
+	if config.RotationKeys.IsUnknown() || config.RotationKeys.IsNull() {
+		return
+	}
+	var rotationKeys []types.String
+	resp.Diagnostics.Append(config.RotationKeys.ElementsAs(ctx, &rotationKeys, false)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	for i, key := range rotationKeys {
+		if key.IsUnknown() || key.IsNull() {
+			continue
+		}
+		_, err := crypto.ParsePublicDIDKey(key.ValueString())
+		if err != nil {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("rotation_keys").AtListIndex(i),
+				"Invalid rotation key",
+				"The rotation key "+key.ValueString()+" is not a valid did:key: "+err.Error(),
+			)
+		}
+	}
+}
+
+func (l *plcRotationKeysResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	// Retrieve values from a plan.
+	var plan plcRotationKeysResourceModel
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+	if diags.HasError() {
+		return
+	}
+
+	var plcToken types.String
+	diags = req.Config.GetAttribute(ctx, path.Root("plc_token"), &plcToken)
+	resp.Diagnostics.Append(diags...)
+	if diags.HasError() {
+		return
+	}
+
+	plan.Did = types.StringValue(l.client.Auth.Did)
+
+	resp.Diagnostics.Append(l.updateRotationKeys(ctx, &plan, plcToken.ValueString())...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// Set state to fully populated data.
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+}
+
+// Read refreshes the Terraform state with the latest data.
+func (l *plcRotationKeysResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	// Get current state.
+	var state plcRotationKeysResourceModel
+	diags := req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	resp.Diagnostics.Append(l.readAuditLog(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// The configured keys must be the highest priority keys, otherwise they were changed outside of Terraform.
+	var activeRotationKeys, rotationKeys []string
+	diags = state.ActiveRotationKeys.ElementsAs(ctx, &activeRotationKeys, false)
+	resp.Diagnostics.Append(diags...)
+	diags = state.RotationKeys.ElementsAs(ctx, &rotationKeys, false)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if !hasRotationKeyPrefix(activeRotationKeys, rotationKeys) {
+		state.RotationKeys = state.ActiveRotationKeys
+	}
+
+	// Set refreshed state.
+	diags = resp.State.Set(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+}
+
+// Update updates the resource and sets the updated Terraform state on success.
+func (l *plcRotationKeysResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	// Retrieve values from a plan.
+	var plan plcRotationKeysResourceModel
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+	if diags.HasError() {
+		return
+	}
+
+	// Get current state.
+	var state plcRotationKeysResourceModel
+	diags = req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	var plcToken types.String
+	diags = req.Config.GetAttribute(ctx, path.Root("plc_token"), &plcToken)
+	resp.Diagnostics.Append(diags...)
+	if diags.HasError() {
+		return
+	}
+
+	if !plan.RotationKeys.Equal(state.RotationKeys) || !plan.IncludePDSRotationKey.Equal(state.IncludePDSRotationKey) {
+		resp.Diagnostics.Append(l.updateRotationKeys(ctx, &plan, plcToken.ValueString())...)
+	} else {
+		resp.Diagnostics.Append(l.readAuditLog(ctx, &plan)...)
+	}
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+}
+
+// Delete removes the Terraform state, the rotation keys are left unchanged.
+func (l *plcRotationKeysResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+}
+
+// Configure adds the provider configured client to the resource.
+func (l *plcRotationKeysResource) Configure(_ context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	// Add a nil check when handling ProviderData because Terraform
+	// sets that data after it calls the ConfigureProvider RPC.
+	if req.ProviderData == nil {
+		return
+	}
+
+	client, ok := req.ProviderData.(*xrpc.Client)
+
+	if !ok {
+		resp.Diagnostics.AddError(
+			"Unexpected Data Source Configure Type",
+			fmt.Sprintf("Expected *xrpc.Client, got: %T. Please report this issue to the provider developers.", req.ProviderData),
+		)
+
+		return
+	}
+
+	l.client = client
+}
+
+// updateRotationKeys signs and submits a PLC operation that sets the rotation keys, then reads back the audit log.
+func (l *plcRotationKeysResource) updateRotationKeys(ctx context.Context, model *plcRotationKeysResourceModel, plcToken string) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	if !strings.HasPrefix(model.Did.ValueString(), "did:plc:") {
+		diags.AddError(
+			"Error updating rotation keys",
+			"Rotation keys can only be managed for did:plc identities, not "+model.Did.ValueString()+".",
+		)
+		return diags
+	}
+
+	var rotationKeys []string
+	diags.Append(model.RotationKeys.ElementsAs(ctx, &rotationKeys, false)...)
+	if diags.HasError() {
+		return diags
+	}
+
+	if model.IncludePDSRotationKey.IsNull() || model.IncludePDSRotationKey.ValueBool() {
+		credentials, err := agnostic.IdentityGetRecommendedDidCredentials(ctx, l.client)
+		if err != nil {
+			diags.Append(xrpcErrorDiagnostic(
+				"Error updating rotation keys",
+				"Could not get the recommended DID credentials from the PDS",
+				err,
+			))
+			return diags
+		}
+		var recommended agnostic.IdentitySignPlcOperation_Input
+		err = json.Unmarshal(*credentials, &recommended)
+		if err != nil {
+			diags.AddError(
+				"Error updating rotation keys",
+				"Could not parse the recommended DID credentials, error: "+err.Error(),
+			)
+			return diags
+		}
+		for _, key := range recommended.RotationKeys {
+			if !slices.Contains(rotationKeys, key) {
+				rotationKeys = append(rotationKeys, key)
+			}
+		}
+	}
+	if len(rotationKeys) > maxPLCRotationKeys {
+		diags.AddAttributeError(
+			path.Root("rotation_keys"),
+			"Too many rotation keys",
+			fmt.Sprintf("Including the PDS's rotation key, there are %d rotation keys, but at most %d are allowed.", len(rotationKeys), maxPLCRotationKeys),
+		)
+		return diags
+	}
+
+	if plcToken == "" {
+		return l.requestPLCToken(ctx, "The PDS has emailed a token to authorize updating the rotation keys.")
+	}
+
+	signedOperation, err := agnostic.IdentitySignPlcOperation(ctx, l.client, &agnostic.IdentitySignPlcOperation_Input{
+		RotationKeys: rotationKeys,
+		Token:        &plcToken,
+	})
+	if isInvalidPLCTokenError(err) {
+		return l.requestPLCToken(ctx, "The configured plc_token is invalid or expired, tokens can only be used once. The PDS has emailed a new token.")
+	}
+	if err != nil {
+		diags.Append(xrpcErrorDiagnostic(
+			"Error updating rotation keys",
+			"Could not sign the PLC operation",
+			err,
+		))
+		return diags
+	}
+	err = agnostic.IdentitySubmitPlcOperation(ctx, l.client, &agnostic.IdentitySubmitPlcOperation_Input{
+		Operation: signedOperation.Operation,
+	})
+	if err != nil {
+		diags.Append(xrpcErrorDiagnostic(
+			"Error updating rotation keys",
+			"Could not submit the PLC operation",
+			err,
+		))
+		return diags
+	}
+
+	diags.Append(l.readAuditLog(ctx, model)...)
+	return diags
+}
+
+// requestPLCToken asks the PDS to email a PLC operation token to the account, and returns the error asking for it to be
+// set in plc_token.
+func (l *plcRotationKeysResource) requestPLCToken(ctx context.Context, detail string) diag.Diagnostics {
+	var diags diag.Diagnostics
+	err := atproto.IdentityRequestPlcOperationSignature(ctx, l.client)
+	if err != nil {
+		diags.Append(xrpcErrorDiagnostic(
+			"Error updating rotation keys",
+			"Could not request a PLC operation token from the PDS",
+			err,
+		))
+		return diags
+	}
+	diags.AddAttributeError(
+		path.Root("plc_token"),
+		"PLC operation token required",
+		detail+" Set plc_token to the token and apply again.",
+	)
+	return diags
+}
+
+// readAuditLog sets the computed attributes from the latest operation in the PLC directory's audit log.
+func (l *plcRotationKeysResource) readAuditLog(ctx context.Context, model *plcRotationKeysResourceModel) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	plcDirectoryURL := defaultPLCDirectoryURL
+	if model.PLCDirectoryURL.ValueString() != "" {
+		plcDirectoryURL = strings.TrimSuffix(model.PLCDirectoryURL.ValueString(), "/")
+	}
+
+	httpClient := http.DefaultClient
+	if l.client != nil && l.client.Client != nil {
+		httpClient = l.client.Client
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, plcDirectoryURL+"/"+model.Did.ValueString()+"/log/audit", nil)
+	if err != nil {
+		diags.AddError(
+			"Error reading PLC audit log",
+			"Could not create the request, error: "+err.Error(),
+		)
+		return diags
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		diags.AddError(
+			"Error reading PLC audit log",
+			"Could not read the audit log of "+model.Did.ValueString()+" from "+plcDirectoryURL+", error: "+err.Error(),
+		)
+		return diags
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		diags.AddError(
+			"Error reading PLC audit log",
+			fmt.Sprintf("Could not read the audit log of %s from %s, HTTP status %d", model.Did.ValueString(), plcDirectoryURL, resp.StatusCode),
+		)
+		return diags
+	}
+
+	var auditLog []plcAuditEntry
+	err = json.NewDecoder(resp.Body).Decode(&auditLog)
+	if err != nil {
+		diags.AddError(
+			"Error reading PLC audit log",
+			"Could not parse the audit log of "+model.Did.ValueString()+", error: "+err.Error(),
+		)
+		return diags
+	}
+
+	var latest *plcAuditEntry
+	for i := range auditLog {
+		if !auditLog[i].Nullified {
+			latest = &auditLog[i]
+		}
+	}
+	if latest == nil {
+		diags.AddError(
+			"Error reading PLC audit log",
+			"The audit log of "+model.Did.ValueString()+" has no operations.",
+		)
+		return diags
+	}
+
+	activeRotationKeys, listDiags := types.ListValueFrom(ctx, types.StringType, latest.Operation.RotationKeys)
+	diags.Append(listDiags...)
+	model.ActiveRotationKeys = activeRotationKeys
+	model.LastOperationCid = types.StringValue(latest.Cid)
+	model.LastOperationAt = types.StringValue(latest.CreatedAt)
+
+	return diags
+}
+
+// hasRotationKeyPrefix returns true if the active rotation keys start with the configured keys.
+func hasRotationKeyPrefix(activeRotationKeys []string, rotationKeys []string) bool {
+	return len(rotationKeys) <= len(activeRotationKeys) && slices.Equal(activeRotationKeys[:len(rotationKeys)], rotationKeys)
+}
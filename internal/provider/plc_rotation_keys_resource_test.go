@@ -0,0 +1,222 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/bluesky-social/indigo/xrpc"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+)
+
+const testPLCDID = "did:plc:testrotation"
+
+// fakePLC is a PDS that signs and submits PLC operations, and a PLC directory serving the resulting audit log.
+type fakePLC struct {
+	server *httptest.Server
+
+	mu sync.Mutex
+	// token is the valid PLC operation token, the PDS emails a new one on every request.
+	token         string
+	tokenRequests int
+	signed        [][]string
+	auditLog      []map[string]any
+}
+
+func newFakePLC(t *testing.T) *fakePLC {
+	t.Helper()
+	f := &fakePLC{
+		token: "token-1",
+		auditLog: []map[string]any{
+			{"did": testPLCDID, "cid": "cid-genesis", "nullified": false, "createdAt": "2024-01-01T00:00:00Z",
+				"operation": map[string]any{"type": "plc_operation", "rotationKeys": []string{"did:key:pds"}}},
+			{"did": testPLCDID, "cid": "cid-nullified", "nullified": true, "createdAt": "2024-02-01T00:00:00Z",
+				"operation": map[string]any{"type": "plc_operation", "rotationKeys": []string{"did:key:attacker"}}},
+		},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /"+testPLCDID+"/log/audit", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		writeTestJSON(w, http.StatusOK, f.auditLog)
+	})
+	mux.HandleFunc("GET /xrpc/com.atproto.identity.getRecommendedDidCredentials", func(w http.ResponseWriter, r *http.Request) {
+		writeTestJSON(w, http.StatusOK, map[string]any{"rotationKeys": []string{"did:key:pds"}})
+	})
+	mux.HandleFunc("POST /xrpc/com.atproto.identity.requestPlcOperationSignature", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.tokenRequests++
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("POST /xrpc/com.atproto.identity.signPlcOperation", func(w http.ResponseWriter, r *http.Request) {
+		var input struct {
+			Token        string   `json:"token"`
+			RotationKeys []string `json:"rotationKeys"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&input)
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if input.Token != f.token {
+			writeTestJSON(w, http.StatusBadRequest, map[string]string{"error": "InvalidToken", "message": "Token is invalid"})
+			return
+		}
+		f.signed = append(f.signed, input.RotationKeys)
+		writeTestJSON(w, http.StatusOK, map[string]any{"operation": map[string]any{"type": "plc_operation", "rotationKeys": input.RotationKeys}})
+	})
+	mux.HandleFunc("POST /xrpc/com.atproto.identity.submitPlcOperation", func(w http.ResponseWriter, r *http.Request) {
+		var input struct {
+			Operation map[string]any `json:"operation"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&input)
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.auditLog = append(f.auditLog, map[string]any{
+			"did": testPLCDID, "cid": "cid-submitted", "nullified": false, "createdAt": "2025-01-01T00:00:00Z", "operation": input.Operation,
+		})
+		w.WriteHeader(http.StatusOK)
+	})
+	f.server = httptest.NewServer(mux)
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+func (f *fakePLC) resource() *plcRotationKeysResource {
+	return &plcRotationKeysResource{client: &xrpc.Client{
+		Host:   f.server.URL,
+		Client: f.server.Client(),
+		Auth:   &xrpc.AuthInfo{Did: testPLCDID},
+	}}
+}
+
+func (f *fakePLC) model(t *testing.T, rotationKeys ...string) *plcRotationKeysResourceModel {
+	t.Helper()
+	keys, diags := types.ListValueFrom(context.Background(), types.StringType, rotationKeys)
+	if diags.HasError() {
+		t.Fatal(diags)
+	}
+	return &plcRotationKeysResourceModel{
+		Did:                   types.StringValue(testPLCDID),
+		RotationKeys:          keys,
+		IncludePDSRotationKey: types.BoolNull(),
+		PLCDirectoryURL:       types.StringValue(f.server.URL + "/"),
+	}
+}
+
+func TestPLCRotationKeysReadAuditLog(t *testing.T) {
+	f := newFakePLC(t)
+	model := f.model(t, "did:key:user")
+
+	diags := f.resource().readAuditLog(context.Background(), model)
+	if diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+	var active []string
+	model.ActiveRotationKeys.ElementsAs(context.Background(), &active, false)
+	if len(active) != 1 || active[0] != "did:key:pds" {
+		t.Errorf("expected the rotation keys of the latest operation that is not nullified, got %v", active)
+	}
+	if model.LastOperationCid.ValueString() != "cid-genesis" || model.LastOperationAt.ValueString() != "2024-01-01T00:00:00Z" {
+		t.Errorf("unexpected latest operation %s at %s", model.LastOperationCid, model.LastOperationAt)
+	}
+}
+
+func TestHasRotationKeyPrefix(t *testing.T) {
+	active := []string{"did:key:a", "did:key:b", "did:key:pds"}
+	for name, test := range map[string]struct {
+		rotationKeys []string
+		want         bool
+	}{
+		"prefix":          {rotationKeys: []string{"did:key:a", "did:key:b"}, want: true},
+		"all keys":        {rotationKeys: []string{"did:key:a", "did:key:b", "did:key:pds"}, want: true},
+		"reordered":       {rotationKeys: []string{"did:key:b", "did:key:a"}, want: false},
+		"not a prefix":    {rotationKeys: []string{"did:key:b"}, want: false},
+		"too many keys":   {rotationKeys: []string{"did:key:a", "did:key:b", "did:key:pds", "did:key:c"}, want: false},
+		"different key":   {rotationKeys: []string{"did:key:a", "did:key:c"}, want: false},
+		"no keys managed": {rotationKeys: nil, want: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if got := hasRotationKeyPrefix(active, test.rotationKeys); got != test.want {
+				t.Errorf("expected %t, got %t", test.want, got)
+			}
+		})
+	}
+}
+
+func TestPLCRotationKeysTokenRequired(t *testing.T) {
+	for name, token := range map[string]string{
+		"missing token": "",
+		"used token":    "token-0",
+	} {
+		t.Run(name, func(t *testing.T) {
+			f := newFakePLC(t)
+			diags := f.resource().updateRotationKeys(context.Background(), f.model(t, "did:key:user"), token)
+			if !diags.HasError() || diags[0].Summary() != "PLC operation token required" {
+				t.Fatalf("expected a token to be required, got %v", diags)
+			}
+			if withPath, ok := diags[0].(diag.DiagnosticWithPath); !ok || !withPath.Path().Equal(path.Root("plc_token")) {
+				t.Errorf("expected the error on plc_token, got %v", diags[0])
+			}
+			if f.tokenRequests != 1 || len(f.signed) != 0 {
+				t.Errorf("expected a new token to be requested without signing, got %d requests and %d signatures", f.tokenRequests, len(f.signed))
+			}
+		})
+	}
+}
+
+func TestPLCRotationKeysUpdate(t *testing.T) {
+	f := newFakePLC(t)
+	model := f.model(t, "did:key:user")
+
+	diags := f.resource().updateRotationKeys(context.Background(), model, "token-1")
+	if diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+	if len(f.signed) != 1 || len(f.signed[0]) != 2 || f.signed[0][0] != "did:key:user" || f.signed[0][1] != "did:key:pds" {
+		t.Errorf("expected the configured key followed by the PDS's key to be signed, got %v", f.signed)
+	}
+	if model.LastOperationCid.ValueString() != "cid-submitted" {
+		t.Errorf("expected the submitted operation to be read back, got %s", model.LastOperationCid)
+	}
+}
+
+func TestPLCRotationKeysValidateConfigUnknownKeys(t *testing.T) {
+	r := &plcRotationKeysResource{}
+	ctx := context.Background()
+	var schemaResp resource.SchemaResponse
+	r.Schema(ctx, resource.SchemaRequest{}, &schemaResp)
+
+	config := tfsdk.Config{Schema: schemaResp.Schema}
+	objectType := schemaResp.Schema.Type().TerraformType(ctx).(tftypes.Object)
+	values := map[string]tftypes.Value{}
+	for name, attributeType := range objectType.AttributeTypes {
+		values[name] = tftypes.NewValue(attributeType, nil)
+	}
+	values["rotation_keys"] = tftypes.NewValue(objectType.AttributeTypes["rotation_keys"], tftypes.UnknownValue)
+	config.Raw = tftypes.NewValue(objectType, values)
+
+	var resp resource.ValidateConfigResponse
+	r.ValidateConfig(ctx, resource.ValidateConfigRequest{Config: config}, &resp)
+	if resp.Diagnostics.HasError() {
+		t.Errorf("expected unknown rotation keys to be accepted, got %v", resp.Diagnostics)
+	}
+
+	values["rotation_keys"] = tftypes.NewValue(objectType.AttributeTypes["rotation_keys"], []tftypes.Value{
+		tftypes.NewValue(tftypes.String, tftypes.UnknownValue),
+		tftypes.NewValue(tftypes.String, "not a key"),
+	})
+	config.Raw = tftypes.NewValue(objectType, values)
+	resp = resource.ValidateConfigResponse{}
+	r.ValidateConfig(ctx, resource.ValidateConfigRequest{Config: config}, &resp)
+	if resp.Diagnostics.ErrorsCount() != 1 || resp.Diagnostics[0].Summary() != "Invalid rotation key" {
+		t.Errorf("expected only the known invalid key to be rejected, got %v", resp.Diagnostics)
+	}
+}
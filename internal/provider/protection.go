@@ -0,0 +1,33 @@
+package provider
+
+import (
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// deletionProtectionAttribute returns the schema of the deletion_protection attribute of a resource managing the kind.
+func deletionProtectionAttribute(kind string) schema.BoolAttribute {
+	return schema.BoolAttribute{
+		Optional: true,
+		MarkdownDescription: "Whether to prevent the " + kind + " from being deleted. While enabled, destroying or replacing the " + kind + " fails, " +
+			"set it to `false` and apply before destroying the " + kind + ". Defaults to `false`.",
+	}
+}
+
+// deletionProtectionDiagnostic returns the error diagnostic for deleting the protected kind with the id.
+func deletionProtectionDiagnostic(kind string, id string) diag.Diagnostic {
+	return diag.NewErrorDiagnostic(
+		"Deletion protection enabled",
+		"The "+kind+" "+id+" cannot be deleted because deletion_protection is enabled. "+
+			"Set deletion_protection to false and apply before destroying the "+kind+".",
+	)
+}
+
+// destroyWarningDiagnostic returns the plan-time warning for destroying the kind, with detail describing what is lost.
+func destroyWarningDiagnostic(kind string, detail string, deletionProtection types.Bool) diag.Diagnostic {
+	if deletionProtection.ValueBool() {
+		detail += " deletion_protection is enabled, so the destroy will fail until it is set to false and applied."
+	}
+	return diag.NewWarningDiagnostic("The "+kind+" will be deleted", detail)
+}
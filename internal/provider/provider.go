@@ -6,8 +6,9 @@ package provider
 import (
 	"context"
 	"os"
+	"strconv"
+	"time"
 
-	"github.com/bluesky-social/indigo/api/atproto"
 	"github.com/bluesky-social/indigo/xrpc"
 
 	"github.com/hashicorp/terraform-plugin-framework/datasource"
@@ -31,10 +32,31 @@ type bskyProvider struct {
 
 // bskyProviderModel maps provider schema data to a Go type.
 type bskyProviderModel struct {
-	PDSHost          types.String `tfsdk:"pds_host"`
-	Handle           types.String `tfsdk:"handle"`
-	Password         types.String `tfsdk:"password"`
-	PDSAdminPassword types.String `tfsdk:"pds_admin_password"`
+	PDSHost            types.String            `tfsdk:"pds_host"`
+	Handle             types.String            `tfsdk:"handle"`
+	Password           types.String            `tfsdk:"password"`
+	PDSAdminPassword   types.String            `tfsdk:"pds_admin_password"`
+	AuthFactorToken    types.String            `tfsdk:"auth_factor_token"`
+	SessionCachePath   types.String            `tfsdk:"session_cache_path"`
+	AccessJwt          types.String            `tfsdk:"access_jwt"`
+	RefreshJwt         types.String            `tfsdk:"refresh_jwt"`
+	OAuth              *bskyProviderOAuthModel `tfsdk:"oauth"`
+	RequestTimeout     types.String            `tfsdk:"request_timeout"`
+	HTTPProxy          types.String            `tfsdk:"http_proxy"`
+	CACertPEM          types.String            `tfsdk:"ca_cert_pem"`
+	CACertFile         types.String            `tfsdk:"ca_cert_file"`
+	InsecureSkipVerify types.Bool              `tfsdk:"insecure_skip_verify"`
+	Headers            map[string]string       `tfsdk:"headers"`
+}
+
+// bskyProviderOAuthModel maps the OAuth provider schema data to a Go type.
+type bskyProviderOAuthModel struct {
+	ClientID          types.String `tfsdk:"client_id"`
+	ClientKeyID       types.String `tfsdk:"client_key_id"`
+	ClientPrivateKey  types.String `tfsdk:"client_private_key"`
+	RedirectURI       types.String `tfsdk:"redirect_uri"`
+	Scope             types.String `tfsdk:"scope"`
+	AuthorizationCode types.String `tfsdk:"authorization_code"`
 }
 
 func (p *bskyProvider) Metadata(ctx context.Context, req provider.MetadataRequest, resp *provider.MetadataResponse) {
@@ -66,6 +88,94 @@ func (p *bskyProvider) Schema(ctx context.Context, req provider.SchemaRequest, r
 					"\nCan also be set via the BSKY_ADMIN_PASSWORD environment variable.",
 				Optional: true,
 			},
+			"auth_factor_token": schema.StringAttribute{
+				MarkdownDescription: "Sign-in code emailed by the PDS when the account has email two-factor authentication enabled." +
+					"\nCan also be set via the BSKY_AUTH_FACTOR_TOKEN environment variable.",
+				Optional:  true,
+				Sensitive: true,
+			},
+			"session_cache_path": schema.StringAttribute{
+				MarkdownDescription: "Directory to cache the Bluesky session in between runs, encrypted with the password, " +
+					"so a new session is only created when the cached one can no longer be refreshed. Disabled by default." +
+					"\nCan also be set via the BSKY_SESSION_CACHE_PATH environment variable.",
+				Optional: true,
+			},
+			"access_jwt": schema.StringAttribute{
+				MarkdownDescription: "Access token of an existing Bluesky session, used instead of `handle` and `password`." +
+					"\nCan also be set via the BSKY_ACCESS_JWT environment variable.",
+				Optional:  true,
+				Sensitive: true,
+			},
+			"refresh_jwt": schema.StringAttribute{
+				MarkdownDescription: "Refresh token of an existing Bluesky session, used instead of `handle` and `password`, and to refresh `access_jwt` when it has expired." +
+					"\nCan also be set via the BSKY_REFRESH_JWT environment variable.",
+				Optional:  true,
+				Sensitive: true,
+			},
+			"oauth": schema.SingleNestedAttribute{
+				MarkdownDescription: "Authenticate as an atproto OAuth confidential client instead of with a password. " +
+					"The authorization server is discovered from the PDS. The first run returns an error with the URL to authorize the provider, " +
+					"and the session is then kept and refreshed in `session_cache_path`, which is required.",
+				Optional: true,
+				Attributes: map[string]schema.Attribute{
+					"client_id": schema.StringAttribute{
+						MarkdownDescription: "URL of the client metadata document",
+						Required:            true,
+					},
+					"client_key_id": schema.StringAttribute{
+						MarkdownDescription: "Key ID (`kid`) of the client key in the client metadata's JWKS",
+						Required:            true,
+					},
+					"client_private_key": schema.StringAttribute{
+						MarkdownDescription: "PEM encoded P-256 private key of the client, used to sign the `private_key_jwt` client assertions",
+						Required:            true,
+						Sensitive:           true,
+					},
+					"redirect_uri": schema.StringAttribute{
+						MarkdownDescription: "Redirect URI registered in the client metadata",
+						Required:            true,
+					},
+					"scope": schema.StringAttribute{
+						MarkdownDescription: "Scope to request. Defaults to `" + defaultOAuthScope + "`.",
+						Optional:            true,
+					},
+					"authorization_code": schema.StringAttribute{
+						MarkdownDescription: "The URL redirected to after authorizing the provider, or its `code` parameter. " +
+							"Only used to complete a pending authorization.",
+						Optional:  true,
+						Sensitive: true,
+					},
+				},
+			},
+			"request_timeout": schema.StringAttribute{
+				MarkdownDescription: "Timeout of each HTTP request, e.g. `30s`. Defaults to no timeout." +
+					"\nCan also be set via the BSKY_REQUEST_TIMEOUT environment variable.",
+				Optional: true,
+			},
+			"http_proxy": schema.StringAttribute{
+				MarkdownDescription: "URL of the proxy to send all HTTP requests through. Defaults to the proxy set by the HTTPS_PROXY and HTTP_PROXY environment variables." +
+					"\nCan also be set via the BSKY_HTTP_PROXY environment variable.",
+				Optional: true,
+			},
+			"ca_cert_pem": schema.StringAttribute{
+				MarkdownDescription: "PEM encoded CA certificates to trust in addition to the system's CA certificates.",
+				Optional:            true,
+			},
+			"ca_cert_file": schema.StringAttribute{
+				MarkdownDescription: "Path to a file with PEM encoded CA certificates to trust in addition to the system's CA certificates." +
+					"\nCan also be set via the BSKY_CA_CERT_FILE environment variable.",
+				Optional: true,
+			},
+			"insecure_skip_verify": schema.BoolAttribute{
+				MarkdownDescription: "Skip verifying the TLS certificates of the servers. Only use this for testing." +
+					"\nCan also be set via the BSKY_INSECURE_SKIP_VERIFY environment variable.",
+				Optional: true,
+			},
+			"headers": schema.MapAttribute{
+				MarkdownDescription: "Extra HTTP headers to send with every request to the PDS. Their values are redacted in the logs.",
+				ElementType:         types.StringType,
+				Optional:            true,
+			},
 		},
 	}
 }
@@ -105,6 +215,22 @@ func (p *bskyProvider) Configure(ctx context.Context, req provider.ConfigureRequ
 				"Either target apply the source of the value first, set the value statically in the configuration, or use the BSKY_PASSWORD environment variable.",
 		)
 	}
+	if config.AccessJwt.IsUnknown() {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("access_jwt"),
+			"Unknown Bluesky access token",
+			"The provider cannot create the Bluesky API client as there is an unknown value for the Bluesky access token. "+
+				"Either target apply the source of the value first, set the value statically in the configuration, or use the BSKY_ACCESS_JWT environment variable.",
+		)
+	}
+	if config.RefreshJwt.IsUnknown() {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("refresh_jwt"),
+			"Unknown Bluesky refresh token",
+			"The provider cannot create the Bluesky API client as there is an unknown value for the Bluesky refresh token. "+
+				"Either target apply the source of the value first, set the value statically in the configuration, or use the BSKY_REFRESH_JWT environment variable.",
+		)
+	}
 
 	if resp.Diagnostics.HasError() {
 		return
@@ -117,6 +243,14 @@ func (p *bskyProvider) Configure(ctx context.Context, req provider.ConfigureRequ
 	handle := os.Getenv("BSKY_HANDLE")
 	password := os.Getenv("BSKY_PASSWORD")
 	pdsAdminpassword := os.Getenv("BSKY_ADMIN_PASSWORD")
+	authFactorToken := os.Getenv("BSKY_AUTH_FACTOR_TOKEN")
+	sessionCachePath := os.Getenv("BSKY_SESSION_CACHE_PATH")
+	accessJwt := os.Getenv("BSKY_ACCESS_JWT")
+	refreshJwt := os.Getenv("BSKY_REFRESH_JWT")
+	requestTimeout := os.Getenv("BSKY_REQUEST_TIMEOUT")
+	httpProxy := os.Getenv("BSKY_HTTP_PROXY")
+	caCertFile := os.Getenv("BSKY_CA_CERT_FILE")
+	insecureSkipVerify := os.Getenv("BSKY_INSECURE_SKIP_VERIFY")
 
 	if !config.PDSHost.IsNull() {
 		pdsHost = config.PDSHost.ValueString()
@@ -134,6 +268,42 @@ func (p *bskyProvider) Configure(ctx context.Context, req provider.ConfigureRequ
 		pdsAdminpassword = config.PDSAdminPassword.ValueString()
 	}
 
+	if !config.AuthFactorToken.IsNull() {
+		authFactorToken = config.AuthFactorToken.ValueString()
+	}
+
+	if !config.SessionCachePath.IsNull() {
+		sessionCachePath = config.SessionCachePath.ValueString()
+	}
+
+	if !config.AccessJwt.IsNull() {
+		accessJwt = config.AccessJwt.ValueString()
+	}
+
+	if !config.RefreshJwt.IsNull() {
+		refreshJwt = config.RefreshJwt.ValueString()
+	}
+
+	if !config.RequestTimeout.IsNull() {
+		requestTimeout = config.RequestTimeout.ValueString()
+	}
+
+	if !config.HTTPProxy.IsNull() {
+		httpProxy = config.HTTPProxy.ValueString()
+	}
+
+	if !config.CACertFile.IsNull() {
+		caCertFile = config.CACertFile.ValueString()
+	}
+
+	if !config.InsecureSkipVerify.IsNull() {
+		insecureSkipVerify = strconv.FormatBool(config.InsecureSkipVerify.ValueBool())
+	}
+
+	// An existing session or OAuth can be used instead of the handle and password.
+	useSessionTokens := accessJwt != "" || refreshJwt != ""
+	useOAuth := config.OAuth != nil
+
 	if pdsHost == "" {
 		resp.Diagnostics.AddAttributeError(
 			path.Root("pds_host"),
@@ -143,64 +313,147 @@ func (p *bskyProvider) Configure(ctx context.Context, req provider.ConfigureRequ
 				"If either is already set, ensure the value is not empty.",
 		)
 	}
-	if handle == "" {
+	if handle == "" && !useSessionTokens && !useOAuth {
 		resp.Diagnostics.AddAttributeError(
 			path.Root("handle"),
 			"Missing Bluesky handle",
 			"The provider cannot create the Bluesky API client as there is a missing or empty value for the Bluesky handle. "+
-				"Set the value in the configuration or use the BSKY_HANDLE environment variable."+
+				"Set the value in the configuration or use the BSKY_HANDLE environment variable, or use access_jwt and refresh_jwt or oauth instead. "+
 				"If either is already set, ensure the value is not empty.",
 		)
 	}
-	if password == "" {
+	if password == "" && !useSessionTokens && !useOAuth {
 		resp.Diagnostics.AddAttributeError(
 			path.Root("password"),
 			"Missing Bluesky password",
 			"The provider cannot create the Bluesky API client as there is a missing or empty value for the Bluesky password. "+
-				"Set the value in the configuration or use the BSKY_PASSWORD environment variable."+
+				"Set the value in the configuration or use the BSKY_PASSWORD environment variable, or use access_jwt and refresh_jwt or oauth instead. "+
 				"If either is already set, ensure the value is not empty.",
 		)
 	}
 
+	settings := httpSettings{
+		proxy:      httpProxy,
+		caCertPEM:  config.CACertPEM.ValueString(),
+		caCertFile: caCertFile,
+	}
+	for name := range config.Headers {
+		settings.headers = append(settings.headers, name)
+	}
+	if requestTimeout != "" {
+		timeout, err := time.ParseDuration(requestTimeout)
+		if err != nil {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("request_timeout"),
+				"Invalid request timeout",
+				"The request timeout must be a duration such as 30s: "+err.Error(),
+			)
+		}
+		settings.timeout = timeout
+	}
+	if insecureSkipVerify != "" {
+		skip, err := strconv.ParseBool(insecureSkipVerify)
+		if err != nil {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("insecure_skip_verify"),
+				"Invalid insecure_skip_verify value",
+				"The BSKY_INSECURE_SKIP_VERIFY environment variable must be true or false: "+err.Error(),
+			)
+		}
+		settings.insecureSkipVerify = skip
+	}
+	httpClient, err := newHTTPClient(settings)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Invalid HTTP configuration",
+			"The provider cannot create the HTTP client: "+err.Error(),
+		)
+	}
+	if settings.insecureSkipVerify {
+		resp.Diagnostics.AddAttributeWarning(
+			path.Root("insecure_skip_verify"),
+			"TLS certificate verification disabled",
+			"The TLS certificates of the servers are not verified, so the credentials and data sent by the provider can be intercepted. "+
+				"Use ca_cert_pem or ca_cert_file to trust a private CA instead.",
+		)
+	}
+
+	var oauth oauthConfig
+	if useOAuth {
+		if sessionCachePath == "" {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("session_cache_path"),
+				"Missing Bluesky session cache path",
+				"The provider cannot use OAuth without a session cache, as the OAuth refresh tokens can only be used once. "+
+					"Set the value in the configuration or use the BSKY_SESSION_CACHE_PATH environment variable.",
+			)
+		}
+		clientKey, err := parseOAuthClientKey(config.OAuth.ClientPrivateKey.ValueString())
+		if err != nil {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("oauth").AtName("client_private_key"),
+				"Invalid OAuth client private key",
+				"The client private key must be a PEM encoded P-256 private key: "+err.Error(),
+			)
+		}
+		oauth = oauthConfig{
+			clientID:          config.OAuth.ClientID.ValueString(),
+			clientKeyID:       config.OAuth.ClientKeyID.ValueString(),
+			clientKey:         clientKey,
+			redirectURI:       config.OAuth.RedirectURI.ValueString(),
+			scope:             defaultOAuthScope,
+			authorizationCode: config.OAuth.AuthorizationCode.ValueString(),
+			loginHint:         handle,
+		}
+		if !config.OAuth.Scope.IsNull() {
+			oauth.scope = config.OAuth.Scope.ValueString()
+		}
+	}
+
 	if resp.Diagnostics.HasError() {
 		return
 	}
 
 	ctx = tflog.SetField(ctx, "bluesky_pds_host", pdsHost)
 	ctx = tflog.SetField(ctx, "bluesky_handle", handle)
-	ctx = tflog.SetField(ctx, "bluesky_password", password)
-	ctx = tflog.SetField(ctx, "bluesky_pds_admin_password", pdsAdminpassword)
-	ctx = tflog.MaskFieldValuesWithFieldKeys(ctx, "bluesky_password")
-	ctx = tflog.MaskFieldValuesWithFieldKeys(ctx, "bluesky_pds_admin_password")
 
 	tflog.Debug(ctx, "Creating Bluesky client")
 
 	// Create a new Bluesky client with the configuration values, and log in
+	userAgent := "terraform-provider-bsky/" + p.version
 	client := &xrpc.Client{
-		Host: pdsHost,
+		Host:      pdsHost,
+		Client:    httpClient,
+		UserAgent: &userAgent,
+		Headers:   config.Headers,
 	}
 	if pdsAdminpassword != "" {
 		// used by com.atproto.server.createInviteCode
 		client.AdminToken = &pdsAdminpassword
 	}
-	authInfo, err := atproto.ServerCreateSession(ctx, client, &atproto.ServerCreateSession_Input{
-		Identifier: handle,
-		Password:   password,
-	})
-	if err != nil {
-		resp.Diagnostics.AddError(
-			"Unable to create Bluesky API client",
-			"An unexpected error occurred when creating the Bluesky API client. "+
-				"If the error is not clear, please contact the provider developers.\n\n"+
-				"XRPC client error: "+err.Error(),
-		)
+	if useOAuth {
+		cache := &sessionCache{
+			dir:      sessionCachePath,
+			pdsHost:  pdsHost,
+			handle:   "oauth " + oauth.clientID + " " + handle,
+			password: config.OAuth.ClientPrivateKey.ValueString(),
+		}
+		resp.Diagnostics.Append(loginWithOAuth(ctx, client, oauth, cache)...)
+	} else if useSessionTokens {
+		resp.Diagnostics.Append(loginWithTokens(ctx, client, accessJwt, refreshJwt)...)
+	} else if sessionCachePath != "" {
+		cache := &sessionCache{
+			dir:      sessionCachePath,
+			pdsHost:  pdsHost,
+			handle:   handle,
+			password: password,
+		}
+		resp.Diagnostics.Append(loginWithCache(ctx, client, cache, authFactorToken)...)
+	} else {
+		resp.Diagnostics.Append(login(ctx, client, handle, password, authFactorToken)...)
 	}
-
-	client.Auth = &xrpc.AuthInfo{
-		AccessJwt:  authInfo.AccessJwt,
-		RefreshJwt: authInfo.RefreshJwt,
-		Did:        authInfo.Did,
-		Handle:     authInfo.Handle,
+	if resp.Diagnostics.HasError() {
+		return
 	}
 
 	// Make the Bluesky client available during DataSource and Resource
@@ -214,15 +467,22 @@ func (p *bskyProvider) Configure(ctx context.Context, req provider.ConfigureRequ
 func (p *bskyProvider) Resources(ctx context.Context) []func() resource.Resource {
 	return []func() resource.Resource{
 		NewAccountResource,
+		NewAccountMigrationResource,
+		NewAppPasswordResource,
+		NewHandleResource,
 		NewListResource,
 		NewListItemResource,
+		NewPLCRotationKeysResource,
+		NewRepoBackupResource,
 		NewStarterPackResource,
 	}
 }
 
 func (p *bskyProvider) DataSources(ctx context.Context) []func() datasource.DataSource {
 	return []func() datasource.DataSource{
+		NewAccountsDataSource,
 		NewListDataSource,
+		NewServerDataSource,
 	}
 }
 
@@ -0,0 +1,324 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+
+	"github.com/bluesky-social/indigo/api/agnostic"
+	"github.com/bluesky-social/indigo/api/atproto"
+	"github.com/bluesky-social/indigo/atproto/syntax"
+	"github.com/bluesky-social/indigo/lex/util"
+	"github.com/bluesky-social/indigo/xrpc"
+	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// Conflict policies for updates of records that were changed outside of Terraform.
+const (
+	conflictPolicyFail      = "fail"
+	conflictPolicyOverwrite = "overwrite"
+	conflictPolicyMerge     = "merge"
+)
+
+// listRecordsPageSize is the maximum number of records returned by a single listRecords call.
+const listRecordsPageSize = 100
+
+// maxApplyWrites is the maximum number of writes in a single applyWrites call.
+const maxApplyWrites = 200
+
+// maxConflictRetries is the number of times an update with the merge conflict policy is retried after a conflict.
+const maxConflictRetries = 3
+
+// conflictPolicyAttribute returns the schema of the conflict_policy attribute of a resource managing the record kind.
+func conflictPolicyAttribute(kind string) schema.StringAttribute {
+	return schema.StringAttribute{
+		Optional: true,
+		Validators: []validator.String{
+			stringvalidator.OneOf(conflictPolicyFail, conflictPolicyOverwrite, conflictPolicyMerge),
+		},
+		MarkdownDescription: "How to update the " + kind + " when it was changed outside of Terraform, e.g. in the app. " +
+			"`fail` fails the update if the " + kind + " changed since Terraform last read it, " +
+			"`overwrite` writes the Terraform-managed fields without checking for changes, " +
+			"and `merge` re-reads the " + kind + " and reapplies the Terraform-managed fields, retrying when it changes concurrently. " +
+			"Fields not managed by Terraform, such as labels, are preserved by all policies. Defaults to `merge`.",
+	}
+}
+
+// rkeyAttribute returns the schema of the rkey attribute of a resource managing the record kind.
+func rkeyAttribute(kind string) schema.StringAttribute {
+	return schema.StringAttribute{
+		Optional: true,
+		Computed: true,
+		Validators: []validator.String{
+			recordKeyValidator{},
+		},
+		PlanModifiers: []planmodifier.String{
+			stringplanmodifier.UseStateForUnknown(),
+			stringplanmodifier.RequiresReplace(),
+		},
+		MarkdownDescription: "Record key of the " + kind + ", the last segment of its URI. " +
+			"If set, the " + kind + " is created with this key so retried applies adopt the same record instead of creating duplicates. " +
+			"Creating fails if a " + kind + " with different content already uses the key, import it instead. " +
+			"Defaults to a key generated by the PDS.",
+	}
+}
+
+// recordKeyValidator validates that a string is a valid record key.
+type recordKeyValidator struct{}
+
+func (v recordKeyValidator) Description(_ context.Context) string {
+	return "value must be a valid record key"
+}
+
+func (v recordKeyValidator) MarkdownDescription(ctx context.Context) string {
+	return v.Description(ctx)
+}
+
+func (v recordKeyValidator) ValidateString(_ context.Context, req validator.StringRequest, resp *validator.StringResponse) {
+	if req.ConfigValue.IsNull() || req.ConfigValue.IsUnknown() {
+		return
+	}
+	if _, err := syntax.ParseRecordKey(req.ConfigValue.ValueString()); err != nil {
+		resp.Diagnostics.AddAttributeError(
+			req.Path,
+			"Invalid record key",
+			"The record key "+req.ConfigValue.ValueString()+" is not valid: "+err.Error(),
+		)
+	}
+}
+
+// createRecord creates a record of the kind in the repo of the client. If rkey is not empty the record is written with
+// that key, otherwise the PDS generates the key. A record already using the key is adopted if it has the same content
+// apart from its creation time, so retried creates are idempotent, and is never overwritten otherwise.
+func createRecord(ctx context.Context, client *xrpc.Client, collection string, rkey string, kind string, record *util.LexiconTypeDecoder) (string, string, diag.Diagnostics) {
+	var diags diag.Diagnostics
+	if rkey == "" {
+		output, err := atproto.RepoCreateRecord(ctx, client, &atproto.RepoCreateRecord_Input{
+			Repo:       client.Auth.Did,
+			Collection: collection,
+			Record:     record,
+		})
+		if err != nil {
+			diags.Append(xrpcErrorDiagnostic("Error creating "+kind, "Could not create "+kind, err))
+			return "", "", diags
+		}
+		return output.Uri, output.Cid, diags
+	}
+
+	// The typed input always sends swapRecord, as null when unset, so the record is written as raw JSON without it.
+	data, err := json.Marshal(record)
+	if err != nil {
+		diags.AddError("Error creating "+kind, "Could not encode "+kind+", error: "+err.Error())
+		return "", "", diags
+	}
+	value, err := decodeRecord((*json.RawMessage)(&data))
+	if err != nil {
+		diags.AddError("Error creating "+kind, "Could not encode "+kind+", error: "+err.Error())
+		return "", "", diags
+	}
+
+	existing, err := agnostic.RepoGetRecord(ctx, client, "", collection, client.Auth.Did, rkey)
+	if err != nil && !isNotFoundError(err) {
+		diags.Append(xrpcErrorDiagnostic(
+			"Error creating "+kind,
+			"Could not check whether a "+kind+" with the record key "+rkey+" already exists",
+			err,
+		))
+		return "", "", diags
+	}
+	if err == nil {
+		current, err := decodeRecord(existing.Value)
+		if err != nil || !recordMatches(current, value) {
+			diags.AddAttributeError(
+				path.Root("rkey"),
+				"Record key already in use",
+				"A "+kind+" with different content already exists at "+existing.Uri+". "+
+					"Import it with terraform import to manage it instead of overwriting it, or choose another rkey.",
+			)
+			return "", "", diags
+		}
+		tflog.Debug(ctx, "Adopting existing record with the same content", map[string]any{"uri": existing.Uri})
+		cid := ""
+		if existing.Cid != nil {
+			cid = *existing.Cid
+		}
+		return existing.Uri, cid, diags
+	}
+
+	output, err := agnostic.RepoPutRecord(ctx, client, &agnostic.RepoPutRecord_Input{
+		Repo:       client.Auth.Did,
+		Collection: collection,
+		Rkey:       rkey,
+		Record:     value,
+	})
+	if err != nil {
+		diags.Append(xrpcErrorDiagnostic("Error creating "+kind, "Could not create "+kind, err))
+		return "", "", diags
+	}
+	return output.Uri, output.Cid, diags
+}
+
+// recordMatches returns true if the existing record has every field of the new record, apart from its creation time.
+// Fields only present in the existing record, such as labels added by the app, are ignored.
+func recordMatches(existing map[string]any, record map[string]any) bool {
+	for k, v := range record {
+		if k == "createdAt" {
+			continue
+		}
+		if !reflect.DeepEqual(existing[k], v) {
+			return false
+		}
+	}
+	return true
+}
+
+// recordKey returns the record key of the record URI, or null if the URI is not valid.
+func recordKey(uri string) types.String {
+	aturi, err := syntax.ParseATURI(uri)
+	if err != nil || aturi.RecordKey() == "" {
+		return types.StringNull()
+	}
+	return types.StringValue(aturi.RecordKey().String())
+}
+
+// updateRecord updates the record at the URI by applying the Terraform-managed fields with update to the current
+// value of the record, following the conflict policy. cid is the version of the record Terraform last read.
+//
+// The record is updated as raw JSON rather than through the typed lexicon structs, so fields unknown to the provider
+// are written back unchanged.
+func updateRecord(ctx context.Context, client *xrpc.Client, uri syntax.ATURI, cid string, policy string, kind string, update func(record map[string]any)) (*agnostic.RepoPutRecord_Output, diag.Diagnostics) {
+	var diags diag.Diagnostics
+	if policy == "" {
+		policy = conflictPolicyMerge
+	}
+
+	for attempt := 0; ; attempt++ {
+		record, err := agnostic.RepoGetRecord(ctx, client, "", uri.Collection().String(), uri.Authority().String(), uri.RecordKey().String())
+		if err != nil {
+			diags.Append(xrpcErrorDiagnostic(
+				"Failed to retrieve "+kind,
+				"Could not retrieve the current state of the "+kind+" "+uri.String(),
+				err,
+			))
+			return nil, diags
+		}
+
+		value, err := decodeRecord(record.Value)
+		if err != nil || value["$type"] != uri.Collection().String() {
+			diags.AddError(
+				"Failed to parse retrieved "+kind,
+				"Could not parse the returned "+kind+" as a "+uri.Collection().String()+" record",
+			)
+			return nil, diags
+		}
+		update(value)
+
+		putRecordInput := &agnostic.RepoPutRecord_Input{
+			Collection: uri.Collection().String(),
+			Repo:       uri.Authority().String(),
+			Rkey:       uri.RecordKey().String(),
+			Record:     value,
+		}
+		switch policy {
+		case conflictPolicyFail:
+			putRecordInput.SwapRecord = record.Cid
+			if cid != "" {
+				putRecordInput.SwapRecord = &cid
+			}
+		case conflictPolicyMerge:
+			putRecordInput.SwapRecord = record.Cid
+		}
+
+		output, err := agnostic.RepoPutRecord(ctx, client, putRecordInput)
+		if err == nil {
+			return output, diags
+		}
+		if policy == conflictPolicyMerge && xrpcErrorName(err) == "InvalidSwap" && attempt < maxConflictRetries {
+			tflog.Debug(ctx, "Record changed concurrently, merging again", map[string]any{
+				"uri":     uri.String(),
+				"attempt": attempt + 1,
+			})
+			continue
+		}
+		diags.Append(xrpcErrorDiagnostic(
+			"Failed to update "+kind,
+			"Could not update "+kind+" "+uri.String(),
+			err,
+		))
+		return nil, diags
+	}
+}
+
+// setRecordDescription sets the description of a record, dropping its facets if the description changed because
+// they index into the previous description.
+func setRecordDescription(record map[string]any, description string) {
+	if record["description"] != description {
+		delete(record, "descriptionFacets")
+	}
+	record["description"] = description
+}
+
+// decodeRecord decodes the JSON value of a record, keeping numbers as written so they round-trip unchanged.
+func decodeRecord(data *json.RawMessage) (map[string]any, error) {
+	if data == nil {
+		return nil, errors.New("record has no value")
+	}
+	decoder := json.NewDecoder(bytes.NewReader(*data))
+	decoder.UseNumber()
+	var value map[string]any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// listRecords returns all records of the collection in the repo.
+func listRecords(ctx context.Context, client *xrpc.Client, repo string, collection string) ([]*agnostic.RepoListRecords_Record, error) {
+	var records []*agnostic.RepoListRecords_Record
+	cursor := ""
+	for {
+		output, err := agnostic.RepoListRecords(ctx, client, collection, cursor, listRecordsPageSize, repo, false, "", "")
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, output.Records...)
+		if output.Cursor == nil || *output.Cursor == "" || len(output.Records) == 0 {
+			return records, nil
+		}
+		cursor = *output.Cursor
+	}
+}
+
+// deleteRecords deletes the records from the repo of the client, in batches of applyWrites calls.
+func deleteRecords(ctx context.Context, client *xrpc.Client, uris []syntax.ATURI) error {
+	for start := 0; start < len(uris); start += maxApplyWrites {
+		end := min(start+maxApplyWrites, len(uris))
+		writes := make([]*atproto.RepoApplyWrites_Input_Writes_Elem, 0, end-start)
+		for _, uri := range uris[start:end] {
+			writes = append(writes, &atproto.RepoApplyWrites_Input_Writes_Elem{
+				RepoApplyWrites_Delete: &atproto.RepoApplyWrites_Delete{
+					Collection: uri.Collection().String(),
+					Rkey:       uri.RecordKey().String(),
+				},
+			})
+		}
+		_, err := atproto.RepoApplyWrites(ctx, client, &atproto.RepoApplyWrites_Input{
+			Repo:   client.Auth.Did,
+			Writes: writes,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
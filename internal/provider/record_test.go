@@ -0,0 +1,412 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/bluesky-social/indigo/api/bsky"
+	"github.com/bluesky-social/indigo/atproto/syntax"
+	"github.com/bluesky-social/indigo/lex/util"
+	"github.com/bluesky-social/indigo/xrpc"
+)
+
+const testRepoDID = "did:plc:testrepo"
+
+// testStarterPack is a starter pack record with fields unknown to the provider, written compactly with sorted keys
+// as the JSON encoder writes it.
+const testStarterPack = `{"$type":"app.bsky.graph.starterpack","createdAt":"2024-01-01T00:00:00.000Z",` +
+	`"description":"Hello @alice.test",` +
+	`"descriptionFacets":[{"features":[{"$type":"app.bsky.richtext.facet#mention","did":"did:plc:alice"}],"index":{"byteEnd":17,"byteStart":6}}],` +
+	`"feeds":[{"uri":"at://did:plc:alice/app.bsky.feed.generator/cats"}],` +
+	`"futureField":{"nested":{"count":12345678901234567890,"ratio":0.1000000000000000055511151231257827,"tags":["a","b"]}},` +
+	`"list":"at://did:plc:testrepo/app.bsky.graph.list/list","name":"Old"}`
+
+// fakeRepo is a PDS serving describeRepo, getRecord, putRecord and listRecords for the records of a single repo.
+type fakeRepo struct {
+	server *httptest.Server
+
+	mu      sync.Mutex
+	records map[string]fakeRepoRecord
+	version int
+	gets    int
+	lists   int
+	puts    []fakeRepoPut
+	// conflicts is the number of following puts that find the record changed concurrently.
+	conflicts int
+}
+
+type fakeRepoRecord struct {
+	cid   string
+	value json.RawMessage
+}
+
+// fakeRepoPut is the body of a putRecord call.
+type fakeRepoPut struct {
+	Collection string          `json:"collection"`
+	Rkey       string          `json:"rkey"`
+	SwapRecord *string         `json:"swapRecord"`
+	Record     json.RawMessage `json:"record"`
+	hasSwap    bool
+}
+
+func newFakeRepo(t *testing.T) *fakeRepo {
+	t.Helper()
+	f := &fakeRepo{records: map[string]fakeRepoRecord{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /xrpc/com.atproto.repo.describeRepo", f.handleDescribeRepo)
+	mux.HandleFunc("GET /xrpc/com.atproto.repo.getRecord", f.handleGetRecord)
+	mux.HandleFunc("POST /xrpc/com.atproto.repo.putRecord", f.handlePutRecord)
+	mux.HandleFunc("GET /xrpc/com.atproto.repo.listRecords", f.handleListRecords)
+	f.server = httptest.NewServer(mux)
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+func (f *fakeRepo) client() *xrpc.Client {
+	return &xrpc.Client{
+		Host:   f.server.URL,
+		Client: f.server.Client(),
+		Auth:   &xrpc.AuthInfo{Did: testRepoDID},
+	}
+}
+
+// set stores the record and returns its URI and CID.
+func (f *fakeRepo) set(collection string, rkey string, value string) (syntax.ATURI, string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.version++
+	cid := "cid-" + strconv.Itoa(f.version)
+	f.records[collection+"/"+rkey] = fakeRepoRecord{cid: cid, value: json.RawMessage(value)}
+	return syntax.ATURI("at://" + testRepoDID + "/" + collection + "/" + rkey), cid
+}
+
+// handleDescribeRepo returns the collections that have records.
+func (f *fakeRepo) handleDescribeRepo(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	collections := []string{}
+	for key := range f.records {
+		if collection := path.Dir(key); !slices.Contains(collections, collection) {
+			collections = append(collections, collection)
+		}
+	}
+	slices.Sort(collections)
+	writeTestJSON(w, http.StatusOK, map[string]any{
+		"did":             testRepoDID,
+		"handle":          "repo.test",
+		"didDoc":          map[string]any{"id": testRepoDID},
+		"collections":     collections,
+		"handleIsCorrect": true,
+	})
+}
+
+func (f *fakeRepo) handleGetRecord(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.gets++
+	query := r.URL.Query()
+	record, ok := f.records[query.Get("collection")+"/"+query.Get("rkey")]
+	if query.Get("repo") != testRepoDID || !ok {
+		writeTestJSON(w, http.StatusBadRequest, map[string]string{"error": "RecordNotFound", "message": "Could not locate record"})
+		return
+	}
+	writeTestJSON(w, http.StatusOK, map[string]any{
+		"uri":   "at://" + testRepoDID + "/" + query.Get("collection") + "/" + query.Get("rkey"),
+		"cid":   record.cid,
+		"value": record.value,
+	})
+}
+
+// handleListRecords returns the records of a collection ordered by record key, two per page.
+func (f *fakeRepo) handleListRecords(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.lists++
+	query := r.URL.Query()
+	var keys []string
+	for key := range f.records {
+		if rkey, ok := strings.CutPrefix(key, query.Get("collection")+"/"); ok && rkey > query.Get("cursor") {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	output := map[string]any{}
+	if len(keys) > 2 {
+		keys = keys[:2]
+		output["cursor"] = path.Base(keys[1])
+	}
+	records := []map[string]any{}
+	for _, key := range keys {
+		records = append(records, map[string]any{
+			"uri":   "at://" + testRepoDID + "/" + key,
+			"cid":   f.records[key].cid,
+			"value": f.records[key].value,
+		})
+	}
+	output["records"] = records
+	writeTestJSON(w, http.StatusOK, output)
+}
+
+func (f *fakeRepo) handlePutRecord(w http.ResponseWriter, r *http.Request) {
+	var put fakeRepoPut
+	var fields map[string]json.RawMessage
+	body, err := io.ReadAll(r.Body)
+	if err == nil {
+		err = json.Unmarshal(body, &fields)
+	}
+	if err == nil {
+		err = json.Unmarshal(body, &put)
+	}
+	if err != nil {
+		writeTestJSON(w, http.StatusBadRequest, map[string]string{"error": "InvalidRequest"})
+		return
+	}
+	_, put.hasSwap = fields["swapRecord"]
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.puts = append(f.puts, put)
+	key := put.Collection + "/" + put.Rkey
+	if f.conflicts > 0 {
+		f.conflicts--
+		f.version++
+		f.records[key] = fakeRepoRecord{cid: "cid-" + strconv.Itoa(f.version), value: f.records[key].value}
+	}
+	if put.SwapRecord != nil && *put.SwapRecord != f.records[key].cid {
+		writeTestJSON(w, http.StatusBadRequest, map[string]string{"error": "InvalidSwap", "message": "Record was at " + f.records[key].cid})
+		return
+	}
+	f.version++
+	cid := "cid-" + strconv.Itoa(f.version)
+	f.records[key] = fakeRepoRecord{cid: cid, value: put.Record}
+	writeTestJSON(w, http.StatusOK, map[string]string{
+		"uri": "at://" + testRepoDID + "/" + key,
+		"cid": cid,
+	})
+}
+
+func TestUpdateRecordPreservesUnknownFields(t *testing.T) {
+	f := newFakeRepo(t)
+	uri, cid := f.set("app.bsky.graph.starterpack", "pack", testStarterPack)
+
+	output, diags := updateRecord(context.Background(), f.client(), uri, cid, conflictPolicyMerge, "starter pack", func(record map[string]any) {
+		record["name"] = "New"
+		setRecordDescription(record, "Hello @alice.test")
+	})
+	if diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+	if output.Cid != f.records["app.bsky.graph.starterpack/pack"].cid {
+		t.Errorf("expected the CID of the written record, got %s", output.Cid)
+	}
+	if len(f.puts) != 1 {
+		t.Fatalf("expected a single put, got %d", len(f.puts))
+	}
+
+	want := strings.Replace(testStarterPack, `"name":"Old"`, `"name":"New"`, 1)
+	if got := string(f.puts[0].Record); got != want {
+		t.Errorf("expected the unknown fields to be written back unchanged\nwant: %s\ngot:  %s", want, got)
+	}
+}
+
+func TestUpdateRecordDescriptionFacets(t *testing.T) {
+	for name, test := range map[string]struct {
+		description string
+		keepsFacets bool
+	}{
+		"unchanged": {description: "Hello @alice.test", keepsFacets: true},
+		"changed":   {description: "Goodbye @alice.test", keepsFacets: false},
+	} {
+		t.Run(name, func(t *testing.T) {
+			f := newFakeRepo(t)
+			uri, cid := f.set("app.bsky.graph.starterpack", "pack", testStarterPack)
+
+			_, diags := updateRecord(context.Background(), f.client(), uri, cid, conflictPolicyMerge, "starter pack", func(record map[string]any) {
+				setRecordDescription(record, test.description)
+			})
+			if diags.HasError() {
+				t.Fatalf("unexpected error: %v", diags)
+			}
+
+			var record map[string]json.RawMessage
+			if err := json.Unmarshal(f.puts[0].Record, &record); err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := record["descriptionFacets"]; ok != test.keepsFacets {
+				t.Errorf("expected descriptionFacets to be kept: %t, got record %s", test.keepsFacets, f.puts[0].Record)
+			}
+			if string(record["description"]) != strconv.Quote(test.description) {
+				t.Errorf("expected description %q, got %s", test.description, record["description"])
+			}
+			if _, ok := record["feeds"]; !ok {
+				t.Errorf("expected the feeds to be kept, got record %s", f.puts[0].Record)
+			}
+		})
+	}
+}
+
+func TestUpdateRecordWrongType(t *testing.T) {
+	f := newFakeRepo(t)
+	uri, cid := f.set("app.bsky.graph.list", "list", `{"$type":"app.bsky.graph.starterpack","name":"Pack"}`)
+
+	_, diags := updateRecord(context.Background(), f.client(), uri, cid, conflictPolicyMerge, "list", func(record map[string]any) {})
+	if !diags.HasError() || diags[0].Summary() != "Failed to parse retrieved list" {
+		t.Fatalf("expected a parse error, got %v", diags)
+	}
+	if len(f.puts) != 0 {
+		t.Errorf("expected no put, got %d", len(f.puts))
+	}
+}
+
+func TestDecodeRecord(t *testing.T) {
+	data := json.RawMessage(testStarterPack)
+	value, err := decodeRecord(&data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nested := value["futureField"].(map[string]any)["nested"].(map[string]any)
+	if count, ok := nested["count"].(json.Number); !ok || count.String() != "12345678901234567890" {
+		t.Errorf("expected the large integer to be kept as written, got %#v", nested["count"])
+	}
+
+	if _, err := decodeRecord(nil); err == nil {
+		t.Error("expected an error for a record without value")
+	}
+	invalid := json.RawMessage(`["not", "an", "object"]`)
+	if _, err := decodeRecord(&invalid); err == nil {
+		t.Error("expected an error for a record that is not an object")
+	}
+}
+
+func TestUpdateRecordConflictPolicies(t *testing.T) {
+	for name, test := range map[string]struct {
+		policy    string
+		staleCid  bool
+		conflicts int
+		wantError bool
+		wantPuts  int
+		wantSwap  string
+	}{
+		"merge":                   {policy: conflictPolicyMerge, wantPuts: 1, wantSwap: "current"},
+		"merge ignores stale cid": {policy: conflictPolicyMerge, staleCid: true, wantPuts: 1, wantSwap: "current"},
+		"merge retries conflicts": {policy: conflictPolicyMerge, conflicts: maxConflictRetries, wantPuts: maxConflictRetries + 1, wantSwap: "current"},
+		"merge gives up":          {policy: conflictPolicyMerge, conflicts: maxConflictRetries + 1, wantError: true, wantPuts: maxConflictRetries + 1, wantSwap: "current"},
+		"default is merge":        {conflicts: 1, wantPuts: 2, wantSwap: "current"},
+		"fail":                    {policy: conflictPolicyFail, wantPuts: 1, wantSwap: "state"},
+		"fail on stale cid":       {policy: conflictPolicyFail, staleCid: true, wantError: true, wantPuts: 1, wantSwap: "state"},
+		"fail on conflict":        {policy: conflictPolicyFail, conflicts: 1, wantError: true, wantPuts: 1, wantSwap: "state"},
+		"overwrite":               {policy: conflictPolicyOverwrite, staleCid: true, conflicts: 1, wantPuts: 1},
+	} {
+		t.Run(name, func(t *testing.T) {
+			f := newFakeRepo(t)
+			uri, cid := f.set("app.bsky.graph.starterpack", "pack", testStarterPack)
+			stateCid := cid
+			if test.staleCid {
+				stateCid = "cid-stale"
+			}
+			f.conflicts = test.conflicts
+
+			_, diags := updateRecord(context.Background(), f.client(), uri, stateCid, test.policy, "starter pack", func(record map[string]any) {
+				record["name"] = "New"
+			})
+			if diags.HasError() != test.wantError {
+				t.Fatalf("expected error: %t, got %v", test.wantError, diags)
+			}
+			if test.wantError && !strings.Contains(diags[0].Detail(), "InvalidSwap") {
+				t.Errorf("expected an InvalidSwap error, got %q", diags[0].Detail())
+			}
+			if len(f.puts) != test.wantPuts || f.gets != test.wantPuts {
+				t.Errorf("expected %d reads and puts, got %d reads and %d puts", test.wantPuts, f.gets, len(f.puts))
+			}
+
+			for i, put := range f.puts {
+				switch test.wantSwap {
+				case "":
+					if put.hasSwap {
+						t.Errorf("put %d: expected no swapRecord, got %v", i, put.SwapRecord)
+					}
+				case "state":
+					if put.SwapRecord == nil || *put.SwapRecord != stateCid {
+						t.Errorf("put %d: expected swapRecord %s, got %v", i, stateCid, put.SwapRecord)
+					}
+				case "current":
+					// Every attempt swaps against the version it read, first cid-1 and then the concurrent writes.
+					want := "cid-" + strconv.Itoa(1+i)
+					if put.SwapRecord == nil || *put.SwapRecord != want {
+						t.Errorf("put %d: expected swapRecord %s, got %v", i, want, put.SwapRecord)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestCreateRecordWithKey(t *testing.T) {
+	for name, test := range map[string]struct {
+		existing  string
+		wantError bool
+		wantPut   bool
+	}{
+		"new record":            {wantPut: true},
+		"same content":          {existing: `{"$type":"app.bsky.graph.list","createdAt":"2024-01-01T00:00:00Z","name":"List","purpose":"app.bsky.graph.defs#curatelist"}`},
+		"same with labels":      {existing: `{"$type":"app.bsky.graph.list","createdAt":"2024-01-01T00:00:00Z","labels":{"$type":"com.atproto.label.defs#selfLabels","values":[]},"name":"List","purpose":"app.bsky.graph.defs#curatelist"}`},
+		"different content":     {existing: `{"$type":"app.bsky.graph.list","createdAt":"2024-01-01T00:00:00Z","name":"Other","purpose":"app.bsky.graph.defs#curatelist"}`, wantError: true},
+		"different type":        {existing: `{"$type":"app.bsky.graph.starterpack","createdAt":"2024-01-01T00:00:00Z","name":"List","purpose":"app.bsky.graph.defs#curatelist"}`, wantError: true},
+		"missing managed field": {existing: `{"$type":"app.bsky.graph.list","createdAt":"2024-01-01T00:00:00Z","name":"List"}`, wantError: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			f := newFakeRepo(t)
+			existingCid := ""
+			if test.existing != "" {
+				_, existingCid = f.set("app.bsky.graph.list", "fixed", test.existing)
+			}
+
+			purpose := "app.bsky.graph.defs#curatelist"
+			uri, cid, diags := createRecord(context.Background(), f.client(), "app.bsky.graph.list", "fixed", "list", &util.LexiconTypeDecoder{Val: &bsky.GraphList{
+				Name:      "List",
+				Purpose:   &purpose,
+				CreatedAt: "2025-06-01T00:00:00Z",
+			}})
+			if diags.HasError() != test.wantError {
+				t.Fatalf("expected error: %t, got %v", test.wantError, diags)
+			}
+			if test.wantError {
+				if diags[0].Summary() != "Record key already in use" {
+					t.Errorf("expected the record key to be in use, got %v", diags)
+				}
+				if len(f.puts) != 0 || string(f.records["app.bsky.graph.list/fixed"].value) != test.existing {
+					t.Errorf("expected the existing record to be left alone, got %d puts", len(f.puts))
+				}
+				return
+			}
+
+			if uri != "at://"+testRepoDID+"/app.bsky.graph.list/fixed" {
+				t.Errorf("expected the record at the fixed key, got %s", uri)
+			}
+			if (len(f.puts) == 1) != test.wantPut {
+				t.Fatalf("expected put: %t, got %d puts", test.wantPut, len(f.puts))
+			}
+			if !test.wantPut {
+				if cid != existingCid {
+					t.Errorf("expected the existing record to be adopted with CID %s, got %s", existingCid, cid)
+				}
+				return
+			}
+			if f.puts[0].hasSwap {
+				t.Errorf("expected no swapRecord, got %v", f.puts[0].SwapRecord)
+			}
+			if cid != f.records["app.bsky.graph.list/fixed"].cid {
+				t.Errorf("expected the CID of the written record, got %s", cid)
+			}
+		})
+	}
+}
@@ -0,0 +1,487 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/bluesky-social/indigo/api/atproto"
+	"github.com/bluesky-social/indigo/atproto/identity"
+	"github.com/bluesky-social/indigo/atproto/repo"
+	"github.com/bluesky-social/indigo/atproto/syntax"
+	"github.com/bluesky-social/indigo/xrpc"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/int64planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/ipfs/go-cid"
+	"github.com/ipld/go-car"
+)
+
+// Ensure the implementation satisfies the expected interfaces.
+var (
+	_ resource.Resource               = &repoBackupResource{}
+	_ resource.ResourceWithConfigure  = &repoBackupResource{}
+	_ resource.ResourceWithModifyPlan = &repoBackupResource{}
+)
+
+// NewRepoBackupResource is a helper function to simplify the provider implementation.
+func NewRepoBackupResource() resource.Resource {
+	return &repoBackupResource{}
+}
+
+// repoBackupResource is the resource implementation.
+type repoBackupResource struct {
+	client *xrpc.Client
+}
+
+type repoBackupResourceModel struct {
+	Did          types.String `tfsdk:"did"`
+	Directory    types.String `tfsdk:"directory"`
+	IncludeBlobs types.Bool   `tfsdk:"include_blobs"`
+	CommitCid    types.String `tfsdk:"commit_cid"`
+	Rev          types.String `tfsdk:"rev"`
+	CarFile      types.String `tfsdk:"car_file"`
+	CarSha256    types.String `tfsdk:"car_sha256"`
+	BlobCount    types.Int64  `tfsdk:"blob_count"`
+}
+
+// Metadata returns the resource type name.
+func (l *repoBackupResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_repo_backup"
+}
+
+// Schema defines the schema for the resource.
+func (r *repoBackupResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "Back up a repo to a local directory as a CAR file named after the repo revision, optionally including its blobs. " +
+			"The commit signature is verified against the DID's signing key and the MST structure is checked. " +
+			"A new backup is only made when the repo revision changed or the CAR file was modified. Destroying this resource keeps the files.",
+		Attributes: map[string]schema.Attribute{
+			"did": schema.StringAttribute{
+				MarkdownDescription: "The DID of the repo to back up. Defaults to the account the provider is authenticated as.",
+				Optional:            true,
+				Computed:            true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"directory": schema.StringAttribute{
+				MarkdownDescription: "Local directory to write the backup to, created if it doesn't exist",
+				Required:            true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"include_blobs": schema.BoolAttribute{
+				MarkdownDescription: "Also download every blob of the repo into the `blobs` subdirectory. Blobs that were already downloaded are skipped.",
+				Optional:            true,
+			},
+			"commit_cid": schema.StringAttribute{
+				MarkdownDescription: "CID of the backed up commit",
+				Computed:            true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"rev": schema.StringAttribute{
+				MarkdownDescription: "Revision of the backed up commit",
+				Computed:            true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"car_file": schema.StringAttribute{
+				MarkdownDescription: "Path of the CAR file",
+				Computed:            true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"car_sha256": schema.StringAttribute{
+				MarkdownDescription: "Hex encoded SHA-256 checksum of the CAR file",
+				Computed:            true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"blob_count": schema.Int64Attribute{
+				MarkdownDescription: "Number of blobs in the backup directory",
+				Computed:            true,
+				PlanModifiers: []planmodifier.Int64{
+					int64planmodifier.UseStateForUnknown(),
+				},
+			},
+		},
+	}
+}
+
+func (l *repoBackupResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	// Retrieve values from a plan.
+	var plan repoBackupResourceModel
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+	if diags.HasError() {
+		return
+	}
+
+	resp.Diagnostics.Append(l.backup(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// Set state to fully populated data.
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+}
+
+// Read refreshes the Terraform state with the latest data.
+func (l *repoBackupResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	// The backup files are checked in ModifyPlan, so changes to them result in a new backup instead of drift.
+}
+
+// Update updates the resource and sets the updated Terraform state on success.
+func (l *repoBackupResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	// Retrieve values from a plan.
+	var plan repoBackupResourceModel
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+	if diags.HasError() {
+		return
+	}
+
+	resp.Diagnostics.Append(l.backup(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+}
+
+// Delete removes the Terraform state, the backup files are kept.
+func (l *repoBackupResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+}
+
+// Configure adds the provider configured client to the resource.
+func (l *repoBackupResource) Configure(_ context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	// Add a nil check when handling ProviderData because Terraform
+	// sets that data after it calls the ConfigureProvider RPC.
+	if req.ProviderData == nil {
+		return
+	}
+
+	client, ok := req.ProviderData.(*xrpc.Client)
+
+	if !ok {
+		resp.Diagnostics.AddError(
+			"Unexpected Data Source Configure Type",
+			fmt.Sprintf("Expected *xrpc.Client, got: %T. Please report this issue to the provider developers.", req.ProviderData),
+		)
+
+		return
+	}
+
+	l.client = client
+}
+
+func (l *repoBackupResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	if req.Plan.Raw.IsNull() || l.client == nil {
+		return
+	}
+
+	var plan repoBackupResourceModel
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+	if diags.HasError() {
+		return
+	}
+
+	if plan.Did.IsUnknown() && l.client.Auth != nil {
+		plan.Did = types.StringValue(l.client.Auth.Did)
+	}
+
+	// Make a new backup only when the repo or the backup files changed.
+	if !req.State.Raw.IsNull() && !plan.Did.IsUnknown() {
+		var state repoBackupResourceModel
+		diags = req.State.Get(ctx, &state)
+		resp.Diagnostics.Append(diags...)
+		if diags.HasError() {
+			return
+		}
+
+		repoClient, _, err := l.repoClient(ctx, plan.Did.ValueString())
+		if err != nil {
+			resp.Diagnostics.AddWarning(
+				"Unable to check repo for changes",
+				"Could not resolve the PDS of "+plan.Did.ValueString()+", error: "+err.Error(),
+			)
+			return
+		}
+		latestCommit, err := atproto.SyncGetLatestCommit(ctx, repoClient, plan.Did.ValueString())
+		if err != nil {
+			resp.Diagnostics.Append(xrpcWarningDiagnostic(
+				"Unable to check repo for changes",
+				"Could not get the latest commit of "+plan.Did.ValueString(),
+				err,
+			))
+			return
+		}
+
+		checksum, err := fileSha256(state.CarFile.ValueString())
+		if latestCommit.Rev != state.Rev.ValueString() || err != nil || checksum != state.CarSha256.ValueString() ||
+			!plan.IncludeBlobs.Equal(state.IncludeBlobs) {
+			plan.CommitCid = types.StringUnknown()
+			plan.Rev = types.StringUnknown()
+			plan.CarFile = types.StringUnknown()
+			plan.CarSha256 = types.StringUnknown()
+			plan.BlobCount = types.Int64Unknown()
+		}
+	}
+
+	diags = resp.Plan.Set(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+}
+
+// repoClient returns an unauthenticated client for the PDS hosting the DID's repo, and the DID's identity.
+func (l *repoBackupResource) repoClient(ctx context.Context, did string) (*xrpc.Client, *identity.Identity, error) {
+	parsedDid, err := syntax.ParseDID(did)
+	if err != nil {
+		return nil, nil, err
+	}
+	ident, err := newIdentityDirectory(l.client).LookupDID(ctx, parsedDid)
+	if err != nil {
+		return nil, nil, err
+	}
+	pdsEndpoint := ident.PDSEndpoint()
+	if pdsEndpoint == "" {
+		return nil, nil, fmt.Errorf("DID document of %s has no PDS endpoint", did)
+	}
+
+	return &xrpc.Client{
+		Host:      pdsEndpoint,
+		Client:    l.client.Client,
+		UserAgent: l.client.UserAgent,
+	}, ident, nil
+}
+
+// backup downloads and verifies the repo, and its blobs if requested, into the backup directory.
+func (l *repoBackupResource) backup(ctx context.Context, model *repoBackupResourceModel) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	did := model.Did.ValueString()
+	if model.Did.IsUnknown() || did == "" {
+		did = l.client.Auth.Did
+		model.Did = types.StringValue(did)
+	}
+	directory := model.Directory.ValueString()
+
+	repoClient, ident, err := l.repoClient(ctx, did)
+	if err != nil {
+		diags.AddError(
+			"Error backing up repo",
+			"Could not resolve the PDS of "+did+", error: "+err.Error(),
+		)
+		return diags
+	}
+
+	carBytes, err := atproto.SyncGetRepo(ctx, repoClient, did, "")
+	if err != nil {
+		diags.Append(xrpcErrorDiagnostic(
+			"Error backing up repo",
+			"Could not download the repo "+did,
+			err,
+		))
+		return diags
+	}
+
+	commitCid, commit, err := verifyRepoCar(ctx, carBytes, ident)
+	if err != nil {
+		diags.AddError(
+			"Error backing up repo",
+			"Could not verify the repo "+did+", error: "+err.Error(),
+		)
+		return diags
+	}
+
+	err = os.MkdirAll(directory, 0o755)
+	if err != nil {
+		diags.AddError(
+			"Error backing up repo",
+			"Could not create the backup directory "+directory+", error: "+err.Error(),
+		)
+		return diags
+	}
+	carFile := filepath.Join(directory, commit.Rev+".car")
+	err = writeFileAtomic(carFile, carBytes)
+	if err != nil {
+		diags.AddError(
+			"Error backing up repo",
+			"Could not write the CAR file "+carFile+", error: "+err.Error(),
+		)
+		return diags
+	}
+	checksum := sha256.Sum256(carBytes)
+
+	model.CommitCid = types.StringValue(commitCid.String())
+	model.Rev = types.StringValue(commit.Rev)
+	model.CarFile = types.StringValue(carFile)
+	model.CarSha256 = types.StringValue(hex.EncodeToString(checksum[:]))
+	model.BlobCount = types.Int64Value(0)
+
+	if !model.IncludeBlobs.ValueBool() {
+		return diags
+	}
+
+	blobDirectory := filepath.Join(directory, "blobs")
+	err = os.MkdirAll(blobDirectory, 0o755)
+	if err != nil {
+		diags.AddError(
+			"Error backing up repo",
+			"Could not create the blob directory "+blobDirectory+", error: "+err.Error(),
+		)
+		return diags
+	}
+
+	blobCount := int64(0)
+	cursor := ""
+	for {
+		blobs, err := atproto.SyncListBlobs(ctx, repoClient, cursor, did, 500, "")
+		if err != nil {
+			diags.Append(xrpcErrorDiagnostic(
+				"Error backing up repo",
+				"Could not list the blobs of "+did,
+				err,
+			))
+			return diags
+		}
+		for _, blobCid := range blobs.Cids {
+			// blobs are content addressed, so existing files don't need to be downloaded again
+			blobFile := filepath.Join(blobDirectory, blobCid)
+			if _, err := os.Stat(blobFile); err == nil {
+				blobCount++
+				continue
+			}
+
+			blob, err := atproto.SyncGetBlob(ctx, repoClient, blobCid, did)
+			if err == nil {
+				err = verifyBlob(blobCid, blob)
+			}
+			if err == nil {
+				err = writeFileAtomic(blobFile, blob)
+			}
+			if err != nil {
+				diags.Append(xrpcErrorDiagnostic(
+					"Error backing up repo",
+					"Could not back up the blob "+blobCid,
+					err,
+				))
+				return diags
+			}
+			tflog.Debug(ctx, "Backed up blob", map[string]any{"cid": blobCid, "size": len(blob)})
+			blobCount++
+		}
+		if blobs.Cursor == nil || *blobs.Cursor == "" || len(blobs.Cids) == 0 {
+			break
+		}
+		cursor = *blobs.Cursor
+	}
+	model.BlobCount = types.Int64Value(blobCount)
+
+	return diags
+}
+
+// verifyRepoCar checks that the CAR file contains a commit signed by the DID's signing key with a valid MST,
+// and returns the commit and its CID.
+func verifyRepoCar(ctx context.Context, carBytes []byte, ident *identity.Identity) (cid.Cid, *repo.Commit, error) {
+	carReader, err := car.NewCarReader(bytes.NewReader(carBytes))
+	if err != nil {
+		return cid.Undef, nil, err
+	}
+	if len(carReader.Header.Roots) < 1 {
+		return cid.Undef, nil, fmt.Errorf("CAR file missing root CID")
+	}
+	commitCid := carReader.Header.Roots[0]
+
+	commit, repository, err := repo.LoadFromCAR(ctx, bytes.NewReader(carBytes))
+	if err != nil {
+		return cid.Undef, nil, err
+	}
+	if commit.DID != ident.DID.String() {
+		return cid.Undef, nil, fmt.Errorf("commit is for %s instead of %s", commit.DID, ident.DID)
+	}
+
+	publicKey, err := ident.PublicKey()
+	if err != nil {
+		return cid.Undef, nil, fmt.Errorf("signing key: %w", err)
+	}
+	err = commit.VerifySignature(publicKey)
+	if err != nil {
+		return cid.Undef, nil, fmt.Errorf("commit signature: %w", err)
+	}
+
+	err = repository.MST.Verify()
+	if err != nil {
+		return cid.Undef, nil, fmt.Errorf("MST: %w", err)
+	}
+	root, err := repository.MST.RootCID()
+	if err != nil {
+		return cid.Undef, nil, fmt.Errorf("MST: %w", err)
+	}
+	if !root.Equals(commit.Data) {
+		return cid.Undef, nil, fmt.Errorf("MST root %s does not match the commit data %s", root, commit.Data)
+	}
+
+	return commitCid, commit, nil
+}
+
+// verifyBlob checks that the blob's content matches its CID.
+func verifyBlob(blobCid string, blob []byte) error {
+	expected, err := cid.Decode(blobCid)
+	if err != nil {
+		return err
+	}
+	actual, err := expected.Prefix().Sum(blob)
+	if err != nil {
+		return err
+	}
+	if !actual.Equals(expected) {
+		return fmt.Errorf("content does not match the CID, got %s", actual)
+	}
+	return nil
+}
+
+// writeFileAtomic writes the file through a temporary file, so an interrupted backup doesn't leave a partial file.
+func writeFileAtomic(name string, data []byte) error {
+	tmp := name + ".tmp"
+	err := os.WriteFile(tmp, data, 0o644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, name)
+}
+
+// fileSha256 returns the hex encoded SHA-256 checksum of the file.
+func fileSha256(name string) (string, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return "", err
+	}
+	checksum := sha256.Sum256(data)
+	return hex.EncodeToString(checksum[:]), nil
+}
@@ -0,0 +1,167 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bluesky-social/indigo/api/atproto"
+	"github.com/bluesky-social/indigo/xrpc"
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// Ensure the implementation satisfies the expected interfaces.
+var (
+	_ datasource.DataSource              = &serverDataSource{}
+	_ datasource.DataSourceWithConfigure = &serverDataSource{}
+)
+
+// NewServerDataSource is a helper function to simplify the provider implementation.
+func NewServerDataSource() datasource.DataSource {
+	return &serverDataSource{}
+}
+
+// serverDataSource is the data source implementation.
+type serverDataSource struct {
+	client *xrpc.Client
+}
+
+// serverDataSourceModel maps the data source schema data.
+type serverDataSourceModel struct {
+	Did                       types.String   `tfsdk:"did"`
+	AvailableUserDomains      []types.String `tfsdk:"available_user_domains"`
+	InviteCodeRequired        types.Bool     `tfsdk:"invite_code_required"`
+	PhoneVerificationRequired types.Bool     `tfsdk:"phone_verification_required"`
+	PrivacyPolicy             types.String   `tfsdk:"privacy_policy"`
+	TermsOfService            types.String   `tfsdk:"terms_of_service"`
+	ContactEmail              types.String   `tfsdk:"contact_email"`
+	Version                   types.String   `tfsdk:"version"`
+}
+
+// serverHealth is the output of the PDS health check endpoint.
+type serverHealth struct {
+	Version string `json:"version"`
+}
+
+// Metadata returns the data source type name.
+func (d *serverDataSource) Metadata(_ context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_server"
+}
+
+// Schema defines the schema for the data source.
+func (d *serverDataSource) Schema(_ context.Context, _ datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "A datasource to retrieve the properties of the PDS the provider is configured with",
+		Attributes: map[string]schema.Attribute{
+			"did": schema.StringAttribute{
+				MarkdownDescription: "The DID of the server",
+				Computed:            true,
+			},
+			"available_user_domains": schema.ListAttribute{
+				MarkdownDescription: "Domain suffixes that can be used in account handles",
+				ElementType:         types.StringType,
+				Computed:            true,
+			},
+			"invite_code_required": schema.BoolAttribute{
+				MarkdownDescription: "Whether an invite code must be supplied to create an account on this server",
+				Computed:            true,
+			},
+			"phone_verification_required": schema.BoolAttribute{
+				MarkdownDescription: "Whether a phone verification token must be supplied to create an account on this server",
+				Computed:            true,
+			},
+			"privacy_policy": schema.StringAttribute{
+				MarkdownDescription: "URL of the server's privacy policy",
+				Computed:            true,
+			},
+			"terms_of_service": schema.StringAttribute{
+				MarkdownDescription: "URL of the server's terms of service",
+				Computed:            true,
+			},
+			"contact_email": schema.StringAttribute{
+				MarkdownDescription: "Contact email of the server's administrators",
+				Computed:            true,
+			},
+			"version": schema.StringAttribute{
+				MarkdownDescription: "Version of the PDS software, as reported by the health check",
+				Computed:            true,
+			},
+		},
+	}
+}
+
+// Read refreshes the Terraform state with the latest data.
+func (d *serverDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	var data serverDataSourceModel
+
+	server, err := atproto.ServerDescribeServer(ctx, d.client)
+	if err != nil {
+		resp.Diagnostics.Append(xrpcErrorDiagnostic(
+			"Unable to Read Server",
+			"Could not describe the PDS",
+			err,
+		))
+		return
+	}
+
+	data.Did = types.StringValue(server.Did)
+	data.AvailableUserDomains = []types.String{}
+	for _, domain := range server.AvailableUserDomains {
+		data.AvailableUserDomains = append(data.AvailableUserDomains, types.StringValue(domain))
+	}
+	data.InviteCodeRequired = types.BoolValue(server.InviteCodeRequired != nil && *server.InviteCodeRequired)
+	data.PhoneVerificationRequired = types.BoolValue(server.PhoneVerificationRequired != nil && *server.PhoneVerificationRequired)
+	data.PrivacyPolicy = types.StringNull()
+	data.TermsOfService = types.StringNull()
+	if server.Links != nil {
+		data.PrivacyPolicy = types.StringPointerValue(server.Links.PrivacyPolicy)
+		data.TermsOfService = types.StringPointerValue(server.Links.TermsOfService)
+	}
+	data.ContactEmail = types.StringNull()
+	if server.Contact != nil {
+		data.ContactEmail = types.StringPointerValue(server.Contact.Email)
+	}
+
+	// The health check is not part of any lexicon, so it is called directly.
+	var health serverHealth
+	err = d.client.Do(ctx, xrpc.Query, "", "_health", nil, nil, &health)
+	if err != nil {
+		resp.Diagnostics.Append(xrpcWarningDiagnostic(
+			"Unable to check server health",
+			"Could not retrieve the PDS version from the health check",
+			err,
+		))
+		data.Version = types.StringNull()
+	} else {
+		data.Version = types.StringValue(health.Version)
+	}
+
+	// Set state
+	diags := resp.State.Set(ctx, &data)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+}
+
+// Configure adds the provider configured client to the data source.
+func (d *serverDataSource) Configure(_ context.Context, req datasource.ConfigureRequest, resp *datasource.ConfigureResponse) {
+	// Add a nil check when handling ProviderData because Terraform
+	// sets that data after it calls the ConfigureProvider RPC.
+	if req.ProviderData == nil {
+		return
+	}
+
+	client, ok := req.ProviderData.(*xrpc.Client)
+	if !ok {
+		resp.Diagnostics.AddError(
+			"Unexpected Data Source Configure Type",
+			fmt.Sprintf("Expected *xrpc.Client, got: %T. Please report this issue to the provider developers.", req.ProviderData),
+		)
+
+		return
+	}
+
+	d.client = client
+}
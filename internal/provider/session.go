@@ -0,0 +1,281 @@
+package provider
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bluesky-social/indigo/api/atproto"
+	"github.com/bluesky-social/indigo/xrpc"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"golang.org/x/crypto/scrypt"
+)
+
+// sessionCache stores the session of a handle on a PDS in a file encrypted with a key derived from the password.
+type sessionCache struct {
+	dir      string
+	pdsHost  string
+	handle   string
+	password string
+}
+
+// fileName returns the name of the cache file, which is derived from the PDS host and handle.
+func (c *sessionCache) fileName() string {
+	checksum := sha256.Sum256([]byte(strings.TrimSuffix(c.pdsHost, "/") + "\n" + strings.ToLower(c.handle)))
+	return filepath.Join(c.dir, hex.EncodeToString(checksum[:])+".session")
+}
+
+// cipher returns the AES-GCM cipher used to encrypt the cache file.
+func (c *sessionCache) cipher() (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(c.password), []byte(filepath.Base(c.fileName())), 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// load decodes the cached session into session, and returns false if there is none.
+func (c *sessionCache) load(session any) (bool, error) {
+	data, err := os.ReadFile(c.fileName())
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	aead, err := c.cipher()
+	if err != nil {
+		return false, err
+	}
+	if len(data) < aead.NonceSize() {
+		return false, fmt.Errorf("session cache file %s is truncated", c.fileName())
+	}
+	plaintext, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], nil)
+	if err != nil {
+		return false, fmt.Errorf("could not decrypt session cache file %s: %w", c.fileName(), err)
+	}
+
+	err = json.Unmarshal(plaintext, session)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// save encrypts the session and writes it to the cache file, readable only by the current user.
+func (c *sessionCache) save(session any) error {
+	plaintext, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+
+	aead, err := c.cipher()
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(c.dir, 0o700)
+	if err != nil {
+		return err
+	}
+	tmp := c.fileName() + ".tmp"
+	err = os.WriteFile(tmp, aead.Seal(nonce, nonce, plaintext, nil), 0o600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.fileName())
+}
+
+// loginWithCache reuses the cached session, refreshing it if needed, and only logs in with the password
+// when there is no cached session or its refresh token is no longer valid.
+func loginWithCache(ctx context.Context, client *xrpc.Client, cache *sessionCache, authFactorToken string) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	var authInfo xrpc.AuthInfo
+	found, err := cache.load(&authInfo)
+	if err != nil {
+		diags.AddWarning(
+			"Unable to read Bluesky session cache",
+			"The cached session could not be read, so a new session will be created. Error: "+err.Error(),
+		)
+	}
+
+	if found {
+		resumed, err := resumeSession(ctx, client, &authInfo)
+		if err != nil {
+			diags.Append(xrpcErrorDiagnostic(
+				"Unable to create Bluesky API client",
+				"Could not resume the cached Bluesky session",
+				err,
+			))
+			return diags
+		}
+		if !resumed {
+			tflog.Debug(ctx, "Cached Bluesky session is no longer valid, creating a new session")
+		}
+	}
+
+	if client.Auth == nil {
+		diags.Append(login(ctx, client, cache.handle, cache.password, authFactorToken)...)
+		if diags.HasError() {
+			return diags
+		}
+	}
+
+	err = cache.save(client.Auth)
+	if err != nil {
+		diags.AddWarning(
+			"Unable to write Bluesky session cache",
+			"The session could not be cached, so the next run will create a new session. Error: "+err.Error(),
+		)
+	}
+
+	return diags
+}
+
+// resumeSession sets the cached session as the client's Auth if it is still valid, refreshing it if the
+// access token has expired. It returns false if the refresh token is no longer valid.
+func resumeSession(ctx context.Context, client *xrpc.Client, authInfo *xrpc.AuthInfo) (bool, error) {
+	client.Auth = &xrpc.AuthInfo{
+		AccessJwt:  authInfo.AccessJwt,
+		RefreshJwt: authInfo.RefreshJwt,
+	}
+	session, err := atproto.ServerGetSession(ctx, client)
+	if err == nil {
+		client.Auth.Did = session.Did
+		client.Auth.Handle = session.Handle
+		return true, nil
+	}
+	if !isInvalidTokenError(err) {
+		client.Auth = nil
+		return false, err
+	}
+
+	// The refresh token is sent in place of the access token to refresh the session.
+	client.Auth.AccessJwt = authInfo.RefreshJwt
+	refreshed, err := atproto.ServerRefreshSession(ctx, client)
+	if err != nil {
+		client.Auth = nil
+		if isInvalidTokenError(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	client.Auth = &xrpc.AuthInfo{
+		AccessJwt:  refreshed.AccessJwt,
+		RefreshJwt: refreshed.RefreshJwt,
+		Did:        refreshed.Did,
+		Handle:     refreshed.Handle,
+	}
+	return true, nil
+}
+
+// loginWithTokens validates the tokens of an existing session and sets it as the client's Auth, refreshing it
+// if the access token has expired.
+func loginWithTokens(ctx context.Context, client *xrpc.Client, accessJwt string, refreshJwt string) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	if accessJwt == "" {
+		// The refresh token is rejected by getSession, so the session is refreshed right away.
+		accessJwt = refreshJwt
+	}
+	resumed, err := resumeSession(ctx, client, &xrpc.AuthInfo{
+		AccessJwt:  accessJwt,
+		RefreshJwt: refreshJwt,
+	})
+	if err != nil {
+		diags.Append(xrpcErrorDiagnostic(
+			"Unable to create Bluesky API client",
+			"Could not validate the Bluesky session tokens",
+			err,
+		))
+		return diags
+	}
+	if !resumed {
+		attribute := path.Root("refresh_jwt")
+		if refreshJwt == "" {
+			attribute = path.Root("access_jwt")
+		}
+		diags.AddAttributeError(
+			attribute,
+			"Invalid Bluesky session tokens",
+			"The access_jwt is expired or invalid, and it could not be refreshed with the refresh_jwt. "+
+				"Issue new session tokens and set them in the configuration or the BSKY_ACCESS_JWT and BSKY_REFRESH_JWT environment variables.",
+		)
+		return diags
+	}
+
+	return diags
+}
+
+// login creates a new session with the handle and password and sets it as the client's Auth.
+func login(ctx context.Context, client *xrpc.Client, handle string, password string, authFactorToken string) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	createSessionInput := &atproto.ServerCreateSession_Input{
+		Identifier: handle,
+		Password:   password,
+	}
+	if authFactorToken != "" {
+		createSessionInput.AuthFactorToken = &authFactorToken
+	}
+	authInfo, err := atproto.ServerCreateSession(ctx, client, createSessionInput)
+	if err != nil {
+		switch name := xrpcErrorName(err); {
+		case name == "AuthFactorTokenRequired":
+			diags.AddAttributeError(
+				path.Root("auth_factor_token"),
+				"Bluesky sign-in code required",
+				"The account has email two-factor authentication enabled, so the PDS has emailed a sign-in code to the account. "+
+					"Set auth_factor_token in the configuration or use the BSKY_AUTH_FACTOR_TOKEN environment variable, then run Terraform again. "+
+					"Set session_cache_path to reuse the session, so the code is only needed once.",
+			)
+			return diags
+		case authFactorToken != "" && (name == "InvalidToken" || name == "ExpiredToken"):
+			// The PDS only emails a new code when signing in without one.
+			diags.AddAttributeError(
+				path.Root("auth_factor_token"),
+				"Invalid Bluesky sign-in code",
+				"The sign-in code is invalid or expired. Remove auth_factor_token and the BSKY_AUTH_FACTOR_TOKEN environment variable "+
+					"and run Terraform again to have a new code emailed to the account, then set the new code.",
+			)
+			return diags
+		}
+		diags.Append(xrpcErrorDiagnostic(
+			"Unable to create Bluesky API client",
+			"Could not create a Bluesky session for "+handle,
+			err,
+		))
+		return diags
+	}
+
+	client.Auth = &xrpc.AuthInfo{
+		AccessJwt:  authInfo.AccessJwt,
+		RefreshJwt: authInfo.RefreshJwt,
+		Did:        authInfo.Did,
+		Handle:     authInfo.Handle,
+	}
+
+	return diags
+}
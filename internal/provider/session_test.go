@@ -0,0 +1,87 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bluesky-social/indigo/xrpc"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+)
+
+// newFakeSignIn returns a PDS for an account with email two-factor authentication enabled and the sign-in code 123456.
+func newFakeSignIn(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/xrpc/com.atproto.server.createSession" {
+			http.NotFound(w, r)
+			return
+		}
+		var input struct {
+			Identifier      string `json:"identifier"`
+			Password        string `json:"password"`
+			AuthFactorToken string `json:"authFactorToken"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&input)
+		switch {
+		case input.Password != "password":
+			writeTestJSON(w, http.StatusUnauthorized, map[string]string{"error": "AuthenticationRequired", "message": "Invalid identifier or password"})
+		case input.AuthFactorToken == "":
+			writeTestJSON(w, http.StatusUnauthorized, map[string]string{"error": "AuthFactorTokenRequired", "message": "A sign in code has been sent to your email address"})
+		case input.AuthFactorToken != "123456":
+			writeTestJSON(w, http.StatusBadRequest, map[string]string{"error": "InvalidToken", "message": "Token is invalid"})
+		default:
+			writeTestJSON(w, http.StatusOK, map[string]any{
+				"accessJwt":  "access",
+				"refreshJwt": "refresh",
+				"did":        "did:plc:testsignin",
+				"handle":     input.Identifier,
+			})
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestLoginAuthFactorToken(t *testing.T) {
+	for name, test := range map[string]struct {
+		password        string
+		authFactorToken string
+		wantSummary     string
+		wantPath        path.Path
+	}{
+		"code missing":   {password: "password", wantSummary: "Bluesky sign-in code required", wantPath: path.Root("auth_factor_token")},
+		"code rejected":  {password: "password", authFactorToken: "000000", wantSummary: "Invalid Bluesky sign-in code", wantPath: path.Root("auth_factor_token")},
+		"code accepted":  {password: "password", authFactorToken: "123456"},
+		"wrong password": {password: "wrong", authFactorToken: "123456", wantSummary: "Unable to create Bluesky API client"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			server := newFakeSignIn(t)
+			client := &xrpc.Client{Host: server.URL, Client: server.Client()}
+
+			diags := login(context.Background(), client, "alice.test", test.password, test.authFactorToken)
+			if test.wantSummary == "" {
+				if diags.HasError() {
+					t.Fatalf("unexpected error: %v", diags)
+				}
+				if client.Auth == nil || client.Auth.Did != "did:plc:testsignin" {
+					t.Errorf("expected the session to be set, got %+v", client.Auth)
+				}
+				return
+			}
+
+			if diags.ErrorsCount() != 1 || diags[0].Summary() != test.wantSummary {
+				t.Fatalf("expected %q, got %v", test.wantSummary, diags)
+			}
+			if len(test.wantPath.Steps()) == 0 {
+				return
+			}
+			if withPath, ok := diags[0].(diag.DiagnosticWithPath); !ok || !withPath.Path().Equal(test.wantPath) {
+				t.Errorf("expected the error on %s, got %v", test.wantPath, diags[0])
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package provider
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"time"
 
@@ -23,6 +24,7 @@ var (
 	_ resource.Resource                = &starterPackResource{}
 	_ resource.ResourceWithConfigure   = &starterPackResource{}
 	_ resource.ResourceWithImportState = &starterPackResource{}
+	_ resource.ResourceWithModifyPlan  = &starterPackResource{}
 )
 
 // NewStarterPackResource is a helper function to simplify the provider implementation.
@@ -36,10 +38,34 @@ type starterPackResource struct {
 }
 
 type starterPackResourceModel struct {
-	Uri         types.String `tfsdk:"uri"`
-	ListUri     types.String `tfsdk:"list_uri"`
-	Name        types.String `tfsdk:"name"`
-	Description types.String `tfsdk:"description"`
+	Cid                types.String `tfsdk:"cid"`
+	Uri                types.String `tfsdk:"uri"`
+	Rkey               types.String `tfsdk:"rkey"`
+	ListUri            types.String `tfsdk:"list_uri"`
+	Name               types.String `tfsdk:"name"`
+	Description        types.String `tfsdk:"description"`
+	ConflictPolicy     types.String `tfsdk:"conflict_policy"`
+	DeletionProtection types.Bool   `tfsdk:"deletion_protection"`
+}
+
+// starterPacksForList returns the URIs of the starter packs in the repo of the client that reference the list.
+func starterPacksForList(ctx context.Context, client *xrpc.Client, listUri string) ([]string, error) {
+	records, err := listRecords(ctx, client, client.Auth.Did, "app.bsky.graph.starterpack")
+	if err != nil {
+		return nil, err
+	}
+
+	var uris []string
+	for _, record := range records {
+		var pack struct {
+			List string `json:"list"`
+		}
+		if record.Value == nil || json.Unmarshal(*record.Value, &pack) != nil || pack.List != listUri {
+			continue
+		}
+		uris = append(uris, record.Uri)
+	}
+	return uris, nil
 }
 
 // Metadata returns the resource type name.
@@ -67,6 +93,13 @@ func (r *starterPackResource) Schema(_ context.Context, _ resource.SchemaRequest
 				MarkdownDescription: "Description of the Starter Pack",
 				Required:            true,
 			},
+			"conflict_policy":     conflictPolicyAttribute("starter pack"),
+			"deletion_protection": deletionProtectionAttribute("starter pack"),
+			"cid": schema.StringAttribute{
+				MarkdownDescription: "Commit ID generated by Bluesky",
+				Computed:            true,
+			},
+			"rkey": rkeyAttribute("starter pack"),
 			"uri": schema.StringAttribute{
 				MarkdownDescription: "Atproto URI",
 				Computed:            true,
@@ -94,24 +127,18 @@ func (l *starterPackResource) Create(ctx context.Context, req resource.CreateReq
 		Name:        plan.Name.ValueString(),
 		Description: plan.Description.ValueStringPointer(),
 	}
-	createRecordInput := &atproto.RepoCreateRecord_Input{
-		Repo:       l.client.Auth.Did,
-		Collection: "app.bsky.graph.starterpack",
-		Record:     &util.LexiconTypeDecoder{Val: item},
-	}
 
 	// Create new pack.
-	record, err := atproto.RepoCreateRecord(ctx, l.client, createRecordInput)
-	if err != nil {
-		resp.Diagnostics.AddError(
-			"Error creating starter pack",
-			"Could not create starter pack, unexpected error: "+err.Error(),
-		)
+	uri, cid, diags := createRecord(ctx, l.client, "app.bsky.graph.starterpack", plan.Rkey.ValueString(), "starter pack", &util.LexiconTypeDecoder{Val: item})
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
 		return
 	}
 
 	// Map response body to schema and populate Computed attribute values.
-	plan.Uri = types.StringValue(record.Uri)
+	plan.Cid = types.StringValue(cid)
+	plan.Uri = types.StringValue(uri)
+	plan.Rkey = recordKey(uri)
 
 	// Set state to fully populated data.
 	diags = resp.State.Set(ctx, plan)
@@ -141,11 +168,16 @@ func (l *starterPackResource) Read(ctx context.Context, req resource.ReadRequest
 		return
 	}
 	record, err := atproto.RepoGetRecord(ctx, l.client, "", uri.Collection().String(), uri.Authority().String(), uri.RecordKey().String())
+	if isNotFoundError(err) {
+		resp.State.RemoveResource(ctx)
+		return
+	}
 	if err != nil {
-		resp.Diagnostics.AddError(
+		resp.Diagnostics.Append(xrpcErrorDiagnostic(
 			"Failed to retrieve starter pack",
-			"Could not retrieve the current state of the starter pack "+state.Uri.ValueString()+": "+err.Error(),
-		)
+			"Could not retrieve the current state of the starter pack "+state.Uri.ValueString(),
+			err,
+		))
 		return
 	}
 	pack, ok := record.Value.Val.(*bsky.GraphStarterpack)
@@ -157,6 +189,8 @@ func (l *starterPackResource) Read(ctx context.Context, req resource.ReadRequest
 		return
 	}
 
+	state.Cid = types.StringPointerValue(record.Cid)
+	state.Rkey = types.StringValue(uri.RecordKey().String())
 	state.Name = types.StringValue(pack.Name)
 	state.Description = types.StringValue(*pack.Description)
 	state.ListUri = types.StringValue(pack.List)
@@ -171,64 +205,51 @@ func (l *starterPackResource) Read(ctx context.Context, req resource.ReadRequest
 
 // Update updates the resource and sets the updated Terraform state on success.
 func (l *starterPackResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
-	// Get current state.
+	// Retrieve values from a plan.
+	var plan starterPackResourceModel
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+	if diags.HasError() {
+		return
+	}
 	var state starterPackResourceModel
-	diags := req.State.Get(ctx, &state)
+	diags = req.State.Get(ctx, &state)
 	resp.Diagnostics.Append(diags...)
-	if resp.Diagnostics.HasError() {
+	if diags.HasError() {
 		return
 	}
 
-	// Generate API request body from plan.
-	uri, err := syntax.ParseATURI(state.Uri.ValueString())
-	if err != nil {
-		resp.Diagnostics.AddError(
-			"Invalid starter pack URI",
-			"Could not parse Bluesky starter pack URI "+state.Uri.ValueString()+": "+err.Error(),
-		)
+	// Only settings such as the conflict policy or deletion protection changed, so the record is left alone.
+	if plan.Name.Equal(state.Name) && plan.Description.Equal(state.Description) {
+		plan.Cid = state.Cid
+		diags = resp.State.Set(ctx, plan)
+		resp.Diagnostics.Append(diags...)
 		return
 	}
-	record, err := atproto.RepoGetRecord(ctx, l.client, "", uri.Collection().String(), uri.Authority().String(), uri.RecordKey().String())
+
+	// Apply the plan to the current starter pack.
+	uri, err := syntax.ParseATURI(plan.Uri.ValueString())
 	if err != nil {
 		resp.Diagnostics.AddError(
-			"Failed to retrieve starter pack",
-			"Could not retrieve the current state of the starter pack "+state.Uri.ValueString()+": "+err.Error(),
+			"Invalid starter pack URI",
+			"Could not parse Bluesky starter pack URI "+plan.Uri.ValueString()+": "+err.Error(),
 		)
 		return
 	}
-	pack, ok := record.Value.Val.(*bsky.GraphStarterpack)
-	if !ok {
-		resp.Diagnostics.AddError(
-			"Failed to parse retrieved starter pack",
-			"Could not cast the returned starter pack into the expected type",
-		)
+	updatedRecord, diags := updateRecord(ctx, l.client, uri, state.Cid.ValueString(), plan.ConflictPolicy.ValueString(), "starter pack", func(record map[string]any) {
+		// Only the Terraform-managed fields are changed, any other fields such as the feeds and labels are preserved.
+		record["name"] = plan.Name.ValueString()
+		setRecordDescription(record, plan.Description.ValueString())
+	})
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
 		return
 	}
 
-	pack.Name = state.Name.ValueString()
-	pack.Description = state.Description.ValueStringPointer()
-	pack.List = state.ListUri.ValueString()
-
-	// Update existing list.
-	putRecordInput := &atproto.RepoPutRecord_Input{
-		Collection: uri.Collection().String(),
-		Repo:       uri.Authority().String(),
-		Rkey:       uri.RecordKey().String(),
-		SwapRecord: record.Cid,
-		Record: &util.LexiconTypeDecoder{
-			Val: pack,
-		},
-	}
-	_, err = atproto.RepoPutRecord(ctx, l.client, putRecordInput)
-	if err != nil {
-		resp.Diagnostics.AddError(
-			"Failed to update starter pack",
-			"Could not update starter pack "+state.Uri.ValueString()+": "+err.Error(),
-		)
-		return
-	}
+	// Update resource state.
+	plan.Cid = types.StringValue(updatedRecord.Cid)
 
-	diags = resp.State.Set(ctx, state)
+	diags = resp.State.Set(ctx, plan)
 	resp.Diagnostics.Append(diags...)
 	if resp.Diagnostics.HasError() {
 		return
@@ -245,6 +266,11 @@ func (l *starterPackResource) Delete(ctx context.Context, req resource.DeleteReq
 		return
 	}
 
+	if state.DeletionProtection.ValueBool() {
+		resp.Diagnostics.Append(deletionProtectionDiagnostic("starter pack", state.Uri.ValueString()))
+		return
+	}
+
 	// Delete existing list.
 	uri, err := syntax.ParseATURI(state.Uri.ValueString())
 	if err != nil {
@@ -261,11 +287,44 @@ func (l *starterPackResource) Delete(ctx context.Context, req resource.DeleteReq
 	}
 	_, err = atproto.RepoDeleteRecord(ctx, l.client, deleteRequest)
 	if err != nil {
-		resp.Diagnostics.AddError(
+		resp.Diagnostics.Append(xrpcErrorDiagnostic(
 			"Error deleting starter pack",
-			"Could not delete starter pack, error: "+err.Error(),
-		)
+			"Could not delete starter pack",
+			err,
+		))
+	}
+}
+
+// ModifyPlan warns at plan time when the starter pack is destroyed or replaced.
+func (l *starterPackResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	if req.State.Raw.IsNull() {
+		return
 	}
+	var state starterPackResourceModel
+	diags := req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+	if diags.HasError() {
+		return
+	}
+
+	action := "Destroying"
+	if !req.Plan.Raw.IsNull() {
+		var plan starterPackResourceModel
+		diags = req.Plan.Get(ctx, &plan)
+		resp.Diagnostics.Append(diags...)
+		if diags.HasError() {
+			return
+		}
+		// A new record key or list replaces the starter pack, which deletes it like a destroy.
+		if plan.Rkey.Equal(state.Rkey) && plan.ListUri.Equal(state.ListUri) {
+			return
+		}
+		action = "Replacing"
+	}
+
+	detail := action + " the starter pack " + state.Name.ValueString() + " (" + state.Uri.ValueString() + ") deletes 1 record, " +
+		"and links to the starter pack stop working. The list " + state.ListUri.ValueString() + " is kept."
+	resp.Diagnostics.Append(destroyWarningDiagnostic("starter pack", detail, state.DeletionProtection))
 }
 
 // Configure adds the provider configured client to the resource.
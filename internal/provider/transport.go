@@ -0,0 +1,90 @@
+package provider
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/bluesky-social/indigo/atproto/identity"
+	"github.com/bluesky-social/indigo/xrpc"
+)
+
+// httpSettings configures the HTTP client used for all requests made by the provider.
+type httpSettings struct {
+	timeout            time.Duration
+	proxy              string
+	caCertPEM          string
+	caCertFile         string
+	insecureSkipVerify bool
+	// headers are the names of the extra headers configured for every request, their values are redacted in the logs.
+	headers []string
+}
+
+// newHTTPClient creates an HTTP client with the configured timeout, proxy and trusted CA certificates, that logs every request.
+func newHTTPClient(settings httpSettings) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if settings.proxy != "" {
+		proxyURL, err := url.Parse(settings.proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL: %w", err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	if settings.caCertPEM != "" || settings.caCertFile != "" {
+		rootCAs, err := x509.SystemCertPool()
+		if err != nil {
+			rootCAs = x509.NewCertPool()
+		}
+		if settings.caCertPEM != "" && !rootCAs.AppendCertsFromPEM([]byte(settings.caCertPEM)) {
+			return nil, errors.New("no certificates found in the CA certificate PEM")
+		}
+		if settings.caCertFile != "" {
+			caCert, err := os.ReadFile(settings.caCertFile)
+			if err != nil {
+				return nil, fmt.Errorf("could not read the CA certificate file: %w", err)
+			}
+			if !rootCAs.AppendCertsFromPEM(caCert) {
+				return nil, fmt.Errorf("no certificates found in the CA certificate file %s", settings.caCertFile)
+			}
+		}
+		transport.TLSClientConfig = &tls.Config{
+			RootCAs: rootCAs,
+		}
+	}
+
+	if settings.insecureSkipVerify {
+		if transport.TLSClientConfig == nil {
+			transport.TLSClientConfig = &tls.Config{}
+		}
+		transport.TLSClientConfig.InsecureSkipVerify = true
+	}
+
+	return &http.Client{
+		Transport: &loggingTransport{
+			base:    transport,
+			trace:   traceLoggingEnabled(),
+			headers: settings.headers,
+		},
+		Timeout: settings.timeout,
+	}, nil
+}
+
+// newIdentityDirectory returns a directory for DID and handle resolution that uses the HTTP client and User-Agent of
+// the provider, so resolution honours the configured timeout, proxy and CA certificates.
+func newIdentityDirectory(client *xrpc.Client) *identity.BaseDirectory {
+	directory := &identity.BaseDirectory{}
+	if client.Client != nil {
+		directory.HTTPClient = *client.Client
+	}
+	if client.UserAgent != nil {
+		directory.UserAgent = *client.UserAgent
+	}
+	return directory
+}